@@ -1,19 +1,73 @@
 package parser
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
 )
 
 // Addition represents additional attributes of a hit object.
 type Addition struct {
-	Sample            string `json:"sample"`           // "normal", "soft", "drum"
-	AdditionalSample  string `json:"additionalSample"` // "normal", "soft", "drum"
+	Sample            SampleSet `json:"sample"`           // Encoded as "normal", "soft", "drum"
+	AdditionalSample  SampleSet `json:"additionalSample"` // Encoded as "normal", "soft", "drum"
+	CustomSampleIndex int       `json:"customSampleIndex"`
+	HitsoundVolume    int       `json:"hitSoundVolume"`
+	Hitsound          string    `json:"hitSound"`
+}
+
+// Sample sets of additions are encoded in lower case, and are empty when inherited.
+func additionSampleName(s SampleSet) string {
+	if s == SampleSetNone {
+		return ""
+	}
+	return strings.ToLower(s.String())
+}
+
+// The JSON encoding of an addition.
+type additionJSON struct {
+	Sample            string `json:"sample"`
+	AdditionalSample  string `json:"additionalSample"`
 	CustomSampleIndex int    `json:"customSampleIndex"`
 	HitsoundVolume    int    `json:"hitSoundVolume"`
 	Hitsound          string `json:"hitSound"`
 }
 
+// MarshalJSON encodes the addition, with sample sets in lower case.
+func (a Addition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(additionJSON{
+		additionSampleName(a.Sample), additionSampleName(a.AdditionalSample),
+		a.CustomSampleIndex, a.HitsoundVolume, a.Hitsound,
+	})
+}
+
+// UnmarshalJSON decodes an addition, with sample sets in lower case.
+func (a *Addition) UnmarshalJSON(data []byte) (err error) {
+	var v additionJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	*a = Addition{CustomSampleIndex: v.CustomSampleIndex, HitsoundVolume: v.HitsoundVolume, Hitsound: v.Hitsound}
+	for _, s := range []struct {
+		Name string
+		Set  *SampleSet
+	}{{v.Sample, &a.Sample}, {v.AdditionalSample, &a.AdditionalSample}} {
+		if *s.Set, err = parseAdditionSample(s.Name); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Decode a lower case sample set name, or "" for none.
+func parseAdditionSample(name string) (SampleSet, error) {
+	for i := SampleSetNone; i <= SampleSetDrum; i++ {
+		if additionSampleName(i) == name {
+			return i, nil
+		}
+	}
+	return SampleSetNone, ParseError("Invalid sample set: " + name)
+}
+
 // Parse additional members
 func parseAddition(str string) (p *Addition, err error) {
 	a := Addition{}
@@ -24,15 +78,15 @@ func parseAddition(str string) (p *Addition, err error) {
 	if len(adds) > 0 && len(adds[0]) > 0 && adds[0] != "0" {
 		switch adds[0] {
 		case "1":
-			a.Sample = "normal"
+			a.Sample = SampleSetNormal
 
 			p = &a
 		case "2":
-			a.Sample = "soft"
+			a.Sample = SampleSetSoft
 
 			p = &a
 		case "3":
-			a.Sample = "drum"
+			a.Sample = SampleSetDrum
 
 			p = &a
 		}
@@ -40,15 +94,15 @@ func parseAddition(str string) (p *Addition, err error) {
 	if len(adds) > 1 && len(adds[1]) > 0 && adds[1] != "0" {
 		switch adds[1] {
 		case "1":
-			a.AdditionalSample = "normal"
+			a.AdditionalSample = SampleSetNormal
 
 			p = &a
 		case "2":
-			a.AdditionalSample = "soft"
+			a.AdditionalSample = SampleSetSoft
 
 			p = &a
 		case "3":
-			a.AdditionalSample = "drum"
+			a.AdditionalSample = SampleSetDrum
 
 			p = &a
 		}
@@ -0,0 +1,46 @@
+package parser
+
+import "image/color"
+
+// Computes the combo number and colour index of every hit object.
+// Like in the game, spinners do not start a combo themselves: they force a new combo
+// on the next object, which also gets their colour skip.
+func (b *Beatmap) computeCombos() {
+	var (
+		skip    = 0     // Colour skip of the spinners before the object
+		started = false // Whether a combo was started
+	)
+	for i := range b.HitObjects {
+		h := &b.HitObjects[i]
+		var prev *HitObject
+		if i > 0 {
+			prev = &b.HitObjects[i-1]
+		}
+		if h.ObjectName == ObjectSpinner {
+			skip += h.ComboColourSkip
+			h.ComboNumber, h.ComboIndex = 1, 0
+			if prev != nil {
+				h.ComboNumber, h.ComboIndex = prev.ComboNumber+1, prev.ComboIndex
+			}
+			continue
+		}
+		switch {
+		case !started:
+			h.ComboNumber, h.ComboIndex = 1, h.ComboColourSkip+skip
+		case h.NewCombo || prev.ObjectName == ObjectSpinner:
+			h.ComboNumber, h.ComboIndex = 1, prev.ComboIndex+1+h.ComboColourSkip+skip
+		default:
+			h.ComboNumber, h.ComboIndex = prev.ComboNumber+1, prev.ComboIndex
+		}
+		started, skip = true, 0
+	}
+}
+
+// ComboColour returns the colour of a hit object, from the beatmap's combo colours.
+// ok is false if the beatmap has no combo colours, and the skin's colours should be used.
+func (b Beatmap) ComboColour(h HitObject) (c color.RGBA, ok bool) {
+	if len(b.Colours.ComboColours) == 0 {
+		return
+	}
+	return b.Colours.ComboColours[h.ComboIndex%len(b.Colours.ComboColours)], true
+}
@@ -0,0 +1,96 @@
+package parser
+
+import "math"
+
+// HitWindows holds the hit windows of a beatmap, in milliseconds.
+// A hit is given a judgement if it is within the window of the
+// judgement on either side of the object's start time.
+// A zero window means the judgement does not exist in the ruleset.
+type HitWindows struct {
+	HitMax float64 // osu!mania only (rainbow 300)
+	Hit300 float64
+	Hit200 float64 // osu!mania only
+	Hit100 float64
+	Hit50  float64
+	Miss   float64 // Presses earlier than this are ignored
+}
+
+// Maps a difficulty value from 0-10 onto a range, given the values at 0, 5 and 10.
+func difficultyRange(d, min, mid, max float64) float64 {
+	if d > 5 {
+		return mid + (max-mid)*(d-5)/5
+	}
+	if d < 5 {
+		return mid - (mid-min)*(5-d)/5
+	}
+	return mid
+}
+
+// HitWindows returns the hit windows of the beatmap, according to its mode
+// and overall difficulty.
+// osu!catch has no hit windows. See ManiaHitWindows for converted osu!mania plays.
+func (b Beatmap) HitWindows() HitWindows {
+	od := b.OverallDifficulty
+	switch b.Mode {
+	case 0: // osu!standard
+		return HitWindows{
+			Hit300: difficultyRange(od, 80, 50, 20),
+			Hit100: difficultyRange(od, 140, 100, 60),
+			Hit50:  difficultyRange(od, 200, 150, 100),
+			Miss:   400,
+		}
+	case 1: // osu!taiko
+		return HitWindows{
+			Hit300: difficultyRange(od, 50, 35, 20),
+			Hit100: difficultyRange(od, 120, 80, 50),
+			Miss:   difficultyRange(od, 135, 95, 70),
+		}
+	case 3: // osu!mania
+		return b.ManiaHitWindows()
+	}
+	return HitWindows{}
+}
+
+// ManiaHitWindows returns the osu!mania hit windows of the beatmap.
+// Beatmaps made for osu!mania have windows scaled by their overall difficulty,
+// while beatmaps of other modes converted to osu!mania have fixed windows,
+// with tighter 300 and 200 windows above OD 4.
+//
+// The game compares integer offsets against truncated windows,
+// which makes every window effectively half a millisecond longer.
+func (b Beatmap) ManiaHitWindows() HitWindows {
+	var w HitWindows
+	if b.Mode == 3 {
+		od := math.Max(0, math.Min(10, b.OverallDifficulty))
+		w = HitWindows{HitMax: 16, Hit300: 64 - 3*od, Hit200: 97 - 3*od, Hit100: 127 - 3*od, Hit50: 151 - 3*od, Miss: 188 - 3*od}
+	} else if math.RoundToEven(b.OverallDifficulty) > 4 {
+		w = HitWindows{HitMax: 16, Hit300: 34, Hit200: 67, Hit100: 97, Hit50: 121, Miss: 158}
+	} else {
+		w = HitWindows{HitMax: 16, Hit300: 47, Hit200: 77, Hit100: 97, Hit50: 121, Miss: 158}
+	}
+	for _, window := range []*float64{&w.HitMax, &w.Hit300, &w.Hit200, &w.Hit100, &w.Hit50, &w.Miss} {
+		*window = math.Floor(*window) + 0.5
+	}
+	return w
+}
+
+// SpinsPerSecond returns the number of rotations per second
+// needed to clear a spinner.
+func (b Beatmap) SpinsPerSecond() float64 {
+	return difficultyRange(b.OverallDifficulty, 3, 5, 7.5)
+}
+
+// Preempt returns the time (in ms) a hit object appears before its start time.
+func (b Beatmap) Preempt() float64 {
+	return difficultyRange(b.ApproachRate, 1800, 1200, 450)
+}
+
+// FadeIn returns the time (in ms) a hit object takes to fully fade in.
+func (b Beatmap) FadeIn() float64 {
+	return difficultyRange(b.ApproachRate, 1200, 800, 300)
+}
+
+// CircleRadius returns the radius of a hit circle, in osu!pixels.
+func (b Beatmap) CircleRadius() float64 {
+	return 64 * circleScale(b.CircleSize)
+}
@@ -0,0 +1,45 @@
+package parser
+
+import "math"
+
+// The playfield height, in osu!pixels.
+const playfieldHeight = 384
+
+// WithMods returns a copy of the beatmap with the difficulty changes of the
+// Easy and HardRock mods applied.
+// HardRock also flips the hit objects vertically.
+// Rate-changing mods are not applied, see WithRate.
+func (b Beatmap) WithMods(mods Mods) Beatmap {
+	switch {
+	case mods.Has(ModHardRock):
+		b.CircleSize = math.Min(10, b.CircleSize*1.3)
+		b.ApproachRate = math.Min(10, b.ApproachRate*1.4)
+		b.OverallDifficulty = math.Min(10, b.OverallDifficulty*1.4)
+		b.HPDrainRate = math.Min(10, b.HPDrainRate*1.4)
+	case mods.Has(ModEasy):
+		b.CircleSize *= 0.5
+		b.ApproachRate *= 0.5
+		b.OverallDifficulty *= 0.5
+		b.HPDrainRate *= 0.5
+	default:
+		return b
+	}
+	hitObjects := make([]HitObject, len(b.HitObjects))
+	copy(hitObjects, b.HitObjects)
+	b.HitObjects = hitObjects
+	if mods.Has(ModHardRock) {
+		flip := func(p Point) Point { return Point{p.X, playfieldHeight - p.Y} }
+		for i := range b.HitObjects {
+			h := &b.HitObjects[i]
+			h.Position = flip(h.Position)
+			h.EndPosition = flip(h.EndPosition)
+			points := make([]Point, len(h.Points))
+			for j, p := range h.Points {
+				points[j] = flip(p)
+			}
+			h.Points = points
+		}
+	}
+	b.computeStacking()
+	return b
+}
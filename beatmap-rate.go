@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+)
+
+// WithRate returns a copy of the beatmap played at the given rate.
+// A rate of 1.5 speeds the beatmap up (like DoubleTime), while a
+// rate of 0.75 slows it down (like HalfTime).
+// All times and beat lengths are scaled, including the storyboard and bookmarks,
+// and the BPM and duration information is recomputed.
+// The copy no longer matches the file, so its MD5 is cleared.
+// The rate must be a finite positive number.
+func (b Beatmap) WithRate(r float64) (Beatmap, error) {
+	if r <= 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+		return b, ParseError("Invalid rate: " + strconv.FormatFloat(r, 'g', -1, 64))
+	}
+	scale := func(t int) int { return int(math.Floor(float64(t)/r + 0.5)) }
+
+	b.MD5 = ""
+	tags := make([]string, len(b.Tags))
+	copy(tags, b.Tags)
+	b.Tags = tags
+	otherAttributes := make(map[string]string, len(b.OtherAttributes))
+	for k, v := range b.OtherAttributes {
+		otherAttributes[k] = v
+	}
+	b.OtherAttributes = otherAttributes
+	bookmarks := make([]int, len(b.Bookmarks))
+	for i, t := range b.Bookmarks {
+		bookmarks[i] = scale(t)
+	}
+	b.Bookmarks = bookmarks
+
+	if b.PreviewTime > 0 {
+		b.PreviewTime = scale(b.PreviewTime)
+	}
+	b.BpmMin = math.Trunc(b.BpmMin*r + 0.5)
+	b.BpmMax = math.Trunc(b.BpmMax*r + 0.5)
+
+	timingPoints := make([]TimingPoint, len(b.TimingPoints))
+	for i, tp := range b.TimingPoints {
+		tp.Offset /= r
+		if tp.BeatLength > 0 {
+			tp.BeatLength /= r
+			tp.Bpm = math.Trunc(60000.0/tp.BeatLength + 0.5)
+		}
+		timingPoints[i] = tp
+	}
+	b.TimingPoints = timingPoints
+
+	breakTimes := make([]BreakTime, len(b.BreakTimes))
+	for i, bt := range b.BreakTimes {
+		breakTimes[i] = BreakTime{scale(bt.StartTime), scale(bt.EndTime)}
+	}
+	b.BreakTimes = breakTimes
+
+	b.VideoOffset = scale(b.VideoOffset)
+	colours := make([]BackgroundColour, len(b.BackgroundColours))
+	for i, bc := range b.BackgroundColours {
+		colours[i] = BackgroundColour{scale(bc.Time), bc.Colour}
+	}
+	b.BackgroundColours = colours
+	b.Storyboard = b.Storyboard.withRate(r, scale)
+
+	hitObjects := make([]HitObject, len(b.HitObjects))
+	for i, h := range b.HitObjects {
+		h.StartTime = scale(h.StartTime)
+		h.EndTime = scale(h.EndTime)
+		if h.Duration > 0 {
+			h.Duration = h.EndTime - h.StartTime
+		}
+		hitObjects[i] = h
+	}
+	b.HitObjects = hitObjects
+
+	// Durations are in seconds, and are recomputed from the objects if there are any
+	b.TotalTime = int(math.Trunc(float64(b.TotalTime) / r))
+	b.DrainingTime = int(math.Trunc(float64(b.DrainingTime) / r))
+	b.computeDuration()
+	return b, nil
+}
+
+// Scale the times of the storyboard, copying every changed slice.
+func (s Storyboard) withRate(r float64, scale func(int) int) Storyboard {
+	scaleCommands := func(cmds []Command) []Command {
+		scaled := make([]Command, len(cmds))
+		for i, c := range cmds {
+			c.StartTime, c.EndTime = scale(c.StartTime), scale(c.EndTime)
+			scaled[i] = c
+		}
+		return scaled
+	}
+	sprites := make([]Sprite, len(s.Sprites))
+	for i, sp := range s.Sprites {
+		sp.FrameDelay /= r
+		sp.Commands = scaleCommands(sp.Commands)
+		loops := make([]Loop, len(sp.Loops))
+		for j, l := range sp.Loops {
+			l.StartTime = scale(l.StartTime)
+			l.Commands = scaleCommands(l.Commands)
+			loops[j] = l
+		}
+		sp.Loops = loops
+		triggers := make([]Trigger, len(sp.Triggers))
+		for j, t := range sp.Triggers {
+			t.StartTime, t.EndTime = scale(t.StartTime), scale(t.EndTime)
+			t.Commands = scaleCommands(t.Commands)
+			triggers[j] = t
+		}
+		sp.Triggers = triggers
+		sprites[i] = sp
+	}
+	samples := make([]Sample, len(s.Samples))
+	for i, sa := range s.Samples {
+		sa.Time = scale(sa.Time)
+		samples[i] = sa
+	}
+	return Storyboard{Sprites: sprites, Samples: samples}
+}
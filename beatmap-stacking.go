@@ -0,0 +1,142 @@
+package parser
+
+// Objects closer than this distance (in osu!pixels) are stacked.
+const stackDistance = 3
+
+// Gets the scale of hit objects for a given circle size.
+func circleScale(cs float64) float64 {
+	return (1 - 0.7*(cs-5)/5) / 2
+}
+
+// Gets the position of the hit object at its end time.
+func (h HitObject) tailPosition() Point {
+	if h.ObjectName == ObjectSlider && h.RepeatCount%2 == 1 {
+		return h.EndPosition
+	}
+	return h.Position
+}
+
+// Gets the end time of any hit object.
+func (h HitObject) endTime() int {
+	if h.EndTime > h.StartTime {
+		return h.EndTime
+	}
+	return h.StartTime
+}
+
+// Compute the stack heights and stacked positions of all hit objects.
+// Only osu!standard beatmaps have their objects stacked.
+func (b *Beatmap) computeStacking() {
+	for i := range b.HitObjects {
+		b.HitObjects[i].StackHeight = 0
+	}
+	if b.Mode == 0 {
+		if b.formatVersion() >= 6 {
+			b.applyStacking()
+		} else {
+			b.applyStackingOld()
+		}
+	}
+	offset := b.StackOffset()
+	for i := range b.HitObjects {
+		h := &b.HitObjects[i]
+		d := float64(h.StackHeight) * offset
+		h.StackedPosition = Point{h.Position.X + d, h.Position.Y + d}
+	}
+}
+
+// StackOffset returns the offset (in osu!pixels, on both axes) applied to
+// an object for every level of stacking it has.
+func (b Beatmap) StackOffset() float64 {
+	return circleScale(b.CircleSize) * -6.4
+}
+
+// Stacking algorithm for file format v6 and above.
+func (b *Beatmap) applyStacking() {
+	var (
+		objs      = b.HitObjects
+		threshold = b.Preempt() * b.StackLeniency
+	)
+	for i := len(objs) - 1; i > 0; i-- {
+		objI := &objs[i]
+		if objI.StackHeight != 0 || objI.ObjectName == ObjectSpinner {
+			continue
+		}
+		switch objI.ObjectName {
+		case ObjectCircle:
+			for n := i - 1; n >= 0; n-- {
+				objN := &objs[n]
+				if objN.ObjectName == ObjectSpinner {
+					continue
+				}
+				if float64(objI.StartTime-objN.endTime()) > threshold {
+					break
+				}
+				// Objects stacked on the end of a slider are pushed the other way.
+				if objN.ObjectName == ObjectSlider && distancePoints(objN.tailPosition(), objI.Position) < stackDistance {
+					offset := objI.StackHeight - objN.StackHeight + 1
+					for j := n + 1; j <= i; j++ {
+						if distancePoints(objN.tailPosition(), objs[j].Position) < stackDistance {
+							objs[j].StackHeight -= offset
+						}
+					}
+					break
+				}
+				if distancePoints(objN.Position, objI.Position) < stackDistance {
+					objN.StackHeight = objI.StackHeight + 1
+					objI = objN
+				}
+			}
+		case ObjectSlider:
+			for n := i - 1; n >= 0; n-- {
+				objN := &objs[n]
+				if objN.ObjectName == ObjectSpinner {
+					continue
+				}
+				if float64(objI.StartTime-objN.StartTime) > threshold {
+					break
+				}
+				if distancePoints(objN.tailPosition(), objI.Position) < stackDistance {
+					objN.StackHeight = objI.StackHeight + 1
+					objI = objN
+				}
+			}
+		}
+	}
+}
+
+// Stacking algorithm for file formats below v6.
+func (b *Beatmap) applyStackingOld() {
+	var (
+		objs      = b.HitObjects
+		threshold = b.Preempt() * b.StackLeniency
+	)
+	for i := range objs {
+		cur := &objs[i]
+		if cur.StackHeight != 0 && cur.ObjectName != ObjectSlider {
+			continue
+		}
+		var (
+			startTime   = float64(cur.endTime())
+			sliderStack = 0
+			endPosition = cur.Position
+		)
+		if cur.ObjectName == ObjectSlider {
+			endPosition = cur.EndPosition
+		}
+		for j := i + 1; j < len(objs); j++ {
+			if float64(objs[j].StartTime)-threshold > startTime {
+				break
+			}
+			if distancePoints(objs[j].Position, cur.Position) < stackDistance {
+				cur.StackHeight++
+				startTime = float64(objs[j].StartTime)
+			} else if distancePoints(objs[j].Position, endPosition) < stackDistance {
+				// Objects stacked on the end of a slider are pushed the other way.
+				sliderStack++
+				objs[j].StackHeight -= sliderStack
+				startTime = float64(objs[j].StartTime)
+			}
+		}
+	}
+}
@@ -43,10 +43,10 @@ func (b *Beatmap) computeMaxCombo() {
 			}
 		}
 		switch h.ObjectName {
-		case "spinner":
-		case "circle":
+		case ObjectSpinner:
+		case ObjectCircle:
 			maxCombo++
-		case "slider":
+		case ObjectSlider:
 			var (
 				osupxPerBeat = sMul * 100 * curTp.Velocity
 				tickLength   = osupxPerBeat / float64(sTick)
@@ -1,5 +1,11 @@
 package parser
 
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
 // Beatmap is the returned struct, representing
 // an osu! beatmap.
 type Beatmap struct {
@@ -13,6 +19,7 @@ type Beatmap struct {
 	Creator       string
 	Source        string
 	Tags          []string
+	MD5           string `json:"md5"` // Checksum of the file, as a hex string
 	// Game Metadata
 	Version           string
 	BeatmapID         int
@@ -20,19 +27,30 @@ type Beatmap struct {
 	FileFormat        string `json:"fileFormat"`
 	Mode              int
 	AudioLeadIn       int
-	SampleSet         string
+	SampleSet         SampleSet
 	BgFilename        string `json:"bgFilename"`
-	Countdown         int
-	BeatDivisor       int
+	VideoFilename     string `json:"videoFilename"`
+	VideoOffset       int    `json:"videoOffset"` // Start time of the video, in ms
+	Countdown         Countdown
+	CountdownOffset   int // Number of beats the countdown is delayed by
 	StackLeniency     float64
-	DistanceSpacing   int
-	GridSize          int
 	LetterboxInBreaks bool
-	PreviewTime       int
-	CircleSize        float64
-	HPDrainRate       float64
-	OverallDifficulty float64
-	ApproachRate      float64
+	// Other [General] settings
+	EpilepsyWarning          bool
+	WidescreenStoryboard     bool
+	SpecialStyle             bool // N+1 key layout in mania
+	UseSkinSprites           bool // Whether the storyboard can use the skin's images
+	SkinPreference           string
+	AlwaysShowPlayfield      bool
+	OverlayPosition          OverlayPosition
+	SamplesMatchPlaybackRate bool
+	StoryFireInFront         bool // Whether the storyboard is drawn in front of combo fire
+	PreviewTime              int
+	CircleSize               float64
+	HPDrainRate              float64
+	OverallDifficulty        float64
+	ApproachRate             float64
+	HasApproachRate          bool `json:"hasApproachRate"` // Whether ApproachRate was present in the file
 	// Beatmap information
 	NbCircles        int     `json:"nbCircles"`
 	NbSliders        int     `json:"nbSliders"`
@@ -47,7 +65,22 @@ type Beatmap struct {
 	TimingPoints     []TimingPoint `json:"timingPoints"`
 	HitObjects       []HitObject   `json:"hitObjects"`
 	BreakTimes       []BreakTime   `json:"breakTimes"`
-	OtherAttributes  map[string]string
+	// Colour changes of the background, sorted by time
+	BackgroundColours []BackgroundColour `json:"backgroundColours"`
+	Storyboard        Storyboard         `json:"storyboard"`
+	Colours           Colours            `json:"colours"`
+	OtherAttributes   map[string]string
+	// Editor state, whose fields are kept at the top level of the JSON output
+	Editor
+}
+
+// Editor holds the [Editor] section of a beatmap.
+type Editor struct {
+	Bookmarks       []int // Times of the bookmarks, in ms
+	DistanceSpacing float64
+	BeatDivisor     int
+	GridSize        int
+	TimelineZoom    float64
 }
 
 func newBeatmap() *Beatmap {
@@ -55,6 +88,19 @@ func newBeatmap() *Beatmap {
 	b.TimingPoints = make([]TimingPoint, 0)
 	b.HitObjects = make([]HitObject, 0)
 	b.BreakTimes = make([]BreakTime, 0)
+	b.BackgroundColours = make([]BackgroundColour, 0)
+	b.Storyboard = newStoryboard()
+	b.Colours.ComboColours = make([]color.RGBA, 0)
+	b.Bookmarks = make([]int, 0)
 	b.OtherAttributes = make(map[string]string)
 	return &b
 }
+
+// Gets the file format version as a number, or 0 if it is unknown.
+func (b Beatmap) formatVersion() int {
+	v, err := strconv.Atoi(strings.TrimPrefix(b.FileFormat, "v"))
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var comboColourReg = regexp.MustCompile("^Combo([0-9]+)$")
+
+// Colours holds the [Colours] section of a beatmap.
+// Missing slider colours are nil, and the skin's colours are used instead.
+type Colours struct {
+	ComboColours        []color.RGBA `json:"comboColours"` // Combo1, Combo2...
+	SliderBody          *color.RGBA  `json:"sliderBody"`
+	SliderTrackOverride *color.RGBA  `json:"sliderTrackOverride"`
+	SliderBorder        *color.RGBA  `json:"sliderBorder"`
+}
+
+// Parse a "r,g,b" or "r,g,b,a" colour.
+func parseColour(str string) (c color.RGBA, err error) {
+	members := strings.Split(str, ",")
+	if len(members) != 3 && len(members) != 4 {
+		return c, ParseError("Invalid colour: " + str)
+	}
+	components := []*uint8{&c.R, &c.G, &c.B, &c.A}
+	c.A = 255
+	for i, m := range members {
+		var v int
+		if v, err = strconv.Atoi(strings.TrimSpace(m)); err != nil {
+			return
+		}
+		if v < 0 || v > 255 {
+			return c, ParseError("Invalid colour: " + str)
+		}
+		*components[i] = uint8(v)
+	}
+	return
+}
+
+// Move the colours out of the other attributes.
+func (b *Beatmap) parseColours() (err error) {
+	type comboColour struct {
+		Index  int
+		Colour color.RGBA
+	}
+	combos := make([]comboColour, 0)
+	for key, value := range b.OtherAttributes {
+		var slider **color.RGBA
+		switch key {
+		case "SliderBody":
+			slider = &b.Colours.SliderBody
+		case "SliderTrackOverride":
+			slider = &b.Colours.SliderTrackOverride
+		case "SliderBorder":
+			slider = &b.Colours.SliderBorder
+		default:
+			match := comboColourReg.FindStringSubmatch(key)
+			if match == nil {
+				continue
+			}
+			c := comboColour{}
+			if c.Index, err = strconv.Atoi(match[1]); err != nil {
+				return
+			}
+			if c.Colour, err = parseColour(value); err != nil {
+				return
+			}
+			combos = append(combos, c)
+			delete(b.OtherAttributes, key)
+			continue
+		}
+		var c color.RGBA
+		if c, err = parseColour(value); err != nil {
+			return
+		}
+		*slider = &c
+		delete(b.OtherAttributes, key)
+	}
+	sort.Slice(combos, func(i, j int) bool { return combos[i].Index < combos[j].Index })
+	b.Colours.ComboColours = make([]color.RGBA, len(combos))
+	for i, c := range combos {
+		b.Colours.ComboColours[i] = c.Colour
+	}
+	return
+}
+
+func formatColour(c color.RGBA) string {
+	if c.A != 255 {
+		return fmt.Sprintf("%d,%d,%d,%d", c.R, c.G, c.B, c.A)
+	}
+	return fmt.Sprintf("%d,%d,%d", c.R, c.G, c.B)
+}
+
+// Write encodes the colours as a [Colours] section.
+func (c Colours) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	w.WriteString("[Colours]\r\n")
+	for i, colour := range c.ComboColours {
+		fmt.Fprintf(w, "Combo%d : %s\r\n", i+1, formatColour(colour))
+	}
+	for _, slider := range []struct {
+		Name   string
+		Colour *color.RGBA
+	}{
+		{"SliderTrackOverride", c.SliderTrackOverride},
+		{"SliderBody", c.SliderBody},
+		{"SliderBorder", c.SliderBorder},
+	} {
+		if slider.Colour != nil {
+			fmt.Fprintf(w, "%s : %s\r\n", slider.Name, formatColour(*slider.Colour))
+		}
+	}
+	return w.Flush()
+}
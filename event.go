@@ -1,6 +1,10 @@
 package parser
 
 import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
 	"regexp"
 	"sort"
 	"strconv"
@@ -13,6 +17,12 @@ type BreakTime struct {
 	EndTime   int `json:"endTime"`
 }
 
+// BackgroundColour is a change of the colour shown behind the background image.
+type BackgroundColour struct {
+	Time   int        `json:"time"`
+	Colour color.RGBA `json:"colour"`
+}
+
 type breakTimeSorter []BreakTime
 
 func (b breakTimeSorter) Len() int           { return len(b) }
@@ -23,16 +33,33 @@ func sortBreakTimes(b []BreakTime) {
 	sort.Sort(breakTimeSorter(b))
 }
 
-// Event is either a background image, or a break time?
+// Removes the spaces and quotes around a filename
+func unquote(name string) string {
+	name = strings.Trim(name, " ")
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// Event is either a background image, a video, or a break time?
 
 func (b *Beatmap) parseEvent(line string) (err error) {
 	members := strings.Split(line, ",")
+	if len(members) < 3 {
+		return
+	}
 	if members[0] == "0" && members[1] == "0" && members[2] != "" {
-		bgName := strings.Trim(members[2], " ")
-		if bgName[0] == '"' && bgName[len(bgName)-1] == '"' {
-			b.BgFilename = bgName[1 : len(bgName)-1]
-		} else {
-			b.BgFilename = bgName
+		b.BgFilename = unquote(members[2])
+	} else if (members[0] == "Video" || members[0] == "1") && members[2] != "" {
+		b.VideoFilename = unquote(members[2])
+		// Like other invalid event lines, an invalid offset is ignored
+		if offset, err := parseStoryboardInt(members[1]); err == nil {
+			b.VideoOffset = offset
+		}
+	} else if members[0] == "3" && len(members) >= 5 {
+		if bc, err := parseBackgroundColour(members); err == nil {
+			b.BackgroundColours = append(b.BackgroundColours, bc)
 		}
 	} else if members[0] == "2" {
 		r := regexp.MustCompile("^[0-9]+$")
@@ -49,3 +76,45 @@ func (b *Beatmap) parseEvent(line string) (err error) {
 	}
 	return
 }
+
+// Parse 3,time,r,g,b
+func parseBackgroundColour(members []string) (bc BackgroundColour, err error) {
+	bc.Colour.A = 255
+	if bc.Time, err = parseStoryboardInt(members[1]); err != nil {
+		return
+	}
+	for i, c := range []*uint8{&bc.Colour.R, &bc.Colour.G, &bc.Colour.B} {
+		var v int
+		if v, err = strconv.Atoi(members[i+2]); err != nil {
+			return
+		}
+		*c = colourComponent(float64(v))
+	}
+	return
+}
+
+// WriteEvents encodes the content of the [Events] section of the beatmap,
+// without the section header: background, video, breaks, storyboard
+// and background colour changes.
+func (b Beatmap) WriteEvents(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	w.WriteString("//Background and Video events\r\n")
+	if b.BgFilename != "" {
+		fmt.Fprintf(w, "0,0,\"%s\",0,0\r\n", b.BgFilename)
+	}
+	if b.VideoFilename != "" {
+		fmt.Fprintf(w, "Video,%d,\"%s\"\r\n", b.VideoOffset, b.VideoFilename)
+	}
+	w.WriteString("//Break Periods\r\n")
+	for _, bt := range b.BreakTimes {
+		fmt.Fprintf(w, "2,%d,%d\r\n", bt.StartTime, bt.EndTime)
+	}
+	b.Storyboard.writeEvents(w)
+	if len(b.BackgroundColours) > 0 {
+		w.WriteString("//Background Colour Transformations\r\n")
+		for _, bc := range b.BackgroundColours {
+			fmt.Fprintf(w, "3,%d,%d,%d,%d\r\n", bc.Time, bc.Colour.R, bc.Colour.G, bc.Colour.B)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Countdown is the speed of the countdown before the first hit object.
+// Unlike the other enums, it is encoded as a number in JSON, as it always has
+// been in the output of this package.
+type Countdown int
+
+// The possible countdowns.
+const (
+	CountdownNone Countdown = iota
+	CountdownNormal
+	CountdownHalf
+	CountdownDouble
+)
+
+var countdownNames = []string{"None", "Normal", "Half", "Double"}
+
+func (c Countdown) String() string {
+	if c >= 0 && int(c) < len(countdownNames) {
+		return countdownNames[c]
+	}
+	return strconv.Itoa(int(c))
+}
+
+// SampleSet is a set of hitsound samples. The same values are used by
+// beatmaps, timing points and hit objects.
+type SampleSet int
+
+// The possible sample sets. SampleSetNone means the sample set is inherited.
+const (
+	SampleSetNone SampleSet = iota
+	SampleSetNormal
+	SampleSetSoft
+	SampleSetDrum
+)
+
+var sampleSetNames = []string{"None", "Normal", "Soft", "Drum"}
+
+func (s SampleSet) String() string {
+	if s >= 0 && int(s) < len(sampleSetNames) {
+		return sampleSetNames[s]
+	}
+	return strconv.Itoa(int(s))
+}
+
+// MarshalJSON encodes the sample set as its name, e.g. "Soft".
+func (s SampleSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a sample set name.
+func (s *SampleSet) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalEnum(data, sampleSetNames)
+	*s = SampleSet(n)
+	return err
+}
+
+// OverlayPosition is the drawing order of hit circle overlays relative to the numbers.
+type OverlayPosition int
+
+// The possible overlay positions.
+const (
+	OverlayNoChange OverlayPosition = iota // Use the skin's setting
+	OverlayBelow
+	OverlayAbove
+)
+
+var overlayPositionNames = []string{"NoChange", "Below", "Above"}
+
+func (o OverlayPosition) String() string {
+	if o >= 0 && int(o) < len(overlayPositionNames) {
+		return overlayPositionNames[o]
+	}
+	return strconv.Itoa(int(o))
+}
+
+// MarshalJSON encodes the overlay position as its name, e.g. "Below".
+func (o OverlayPosition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// UnmarshalJSON decodes an overlay position name.
+func (o *OverlayPosition) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalEnum(data, overlayPositionNames)
+	*o = OverlayPosition(n)
+	return err
+}
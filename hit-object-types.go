@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ObjectKind is the kind of a hit object.
+type ObjectKind int
+
+// The possible hit object kinds.
+const (
+	ObjectUnknown ObjectKind = iota // e.g. mania hold notes
+	ObjectCircle
+	ObjectSlider
+	ObjectSpinner
+)
+
+var objectKindNames = []string{"unknown", "circle", "slider", "spinner"}
+
+func (k ObjectKind) String() string {
+	if k >= 0 && int(k) < len(objectKindNames) {
+		return objectKindNames[k]
+	}
+	return strconv.Itoa(int(k))
+}
+
+// MarshalJSON encodes the kind as its name, e.g. "slider".
+func (k ObjectKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// UnmarshalJSON decodes a kind name.
+func (k *ObjectKind) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalEnum(data, objectKindNames)
+	*k = ObjectKind(n)
+	return err
+}
+
+// CurveType is the type of curve of a slider.
+type CurveType int
+
+// The possible curve types.
+const (
+	CurveNone CurveType = iota // Not a slider
+	CurveLinear
+	CurveBezier
+	CurveCatmull
+	CurvePassThrough // Circular arc through three points
+	CurveUnknown
+)
+
+var curveTypeNames = []string{"", "linear", "bezier", "catmull", "pass-through", "unknown"}
+
+func (c CurveType) String() string {
+	if c >= 0 && int(c) < len(curveTypeNames) {
+		return curveTypeNames[c]
+	}
+	return strconv.Itoa(int(c))
+}
+
+// MarshalJSON encodes the curve type as its name, e.g. "pass-through".
+func (c CurveType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON decodes a curve type name.
+func (c *CurveType) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalEnum(data, curveTypeNames)
+	*c = CurveType(n)
+	return err
+}
+
+// HitSound holds the hitsound bitflags of a hit object or slider edge,
+// as they are in the file.
+type HitSound int
+
+// The hitsound flags. The normal sound is always played,
+// whether HitSoundNormal is set or not.
+const (
+	HitSoundNormal HitSound = 1 << iota
+	HitSoundWhistle
+	HitSoundFinish
+	HitSoundClap
+)
+
+// Has tells whether every given flag is set.
+func (h HitSound) Has(flags HitSound) bool {
+	return h&flags == flags
+}
+
+// Names returns the names of the additional sounds played, e.g. ["whistle", "clap"],
+// or ["normal"] if there are none.
+func (h HitSound) Names() []string {
+	names := make([]string, 0)
+	for _, s := range []struct {
+		Flag HitSound
+		Name string
+	}{{HitSoundWhistle, "whistle"}, {HitSoundFinish, "finish"}, {HitSoundClap, "clap"}} {
+		if h.Has(s.Flag) {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "normal")
+	}
+	return names
+}
+
+func (h HitSound) String() string {
+	return strings.Join(h.Names(), ",")
+}
+
+// MarshalJSON encodes the hitsound as the list of its names.
+func (h HitSound) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Names())
+}
+
+// UnmarshalJSON decodes a list of hitsound names.
+func (h *HitSound) UnmarshalJSON(data []byte) (err error) {
+	var names []string
+	if err = json.Unmarshal(data, &names); err != nil {
+		return
+	}
+	*h = 0
+	for _, name := range names {
+		switch name {
+		case "normal":
+		case "whistle":
+			*h |= HitSoundWhistle
+		case "finish":
+			*h |= HitSoundFinish
+		case "clap":
+			*h |= HitSoundClap
+		default:
+			return ParseError("Invalid hitsound: " + name)
+		}
+	}
+	return
+}
+
+// Decode an enum value from its name.
+// Unlike in files, numbers are not accepted: they could be out of range.
+func unmarshalEnum(data []byte, names []string) (n int, err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	for i, name := range names {
+		if str == name {
+			return i, nil
+		}
+	}
+	return 0, ParseError("Invalid value: " + str)
+}
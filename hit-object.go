@@ -7,29 +7,38 @@ import (
 	"strings"
 )
 
-var curveTypes = map[string]string{
-	"C": "catmull",
-	"B": "bezier",
-	"L": "linear",
-	"P": "pass-through",
+var curveTypes = map[string]CurveType{
+	"C": CurveCatmull,
+	"B": CurveBezier,
+	"L": CurveLinear,
+	"P": CurvePassThrough,
 }
 
 // HitObject represents an osu! hit object.
 type HitObject struct {
-	ObjectName  string    `json:"objectName"` // "slider", "spinner", "circle"
-	StartTime   int       `json:"startTime"`
-	EndTime     int       `json:"endTime"`     // Spinner only
-	RepeatCount int       `json:"repeatCount"` // Sliders only
-	PixelLength float64   `json:"pixelLength"` // Sliders only
-	Points      []Point   `json:"points"`      // Sliders only
-	Duration    int       `json:"duration"`    // Sliders only
-	CurveType   string    `json:"curveType"`   // Sliders only, "catmull", "bezier", "linear", "pass-through"
-	EndPosition Point     `json:"endPosition"` // Sliders only
-	Edges       []Edge    `json:"edges"`
-	NewCombo    bool      `json:"newCombo"`
-	SoundTypes  []string  `json:"soundTypes"` // contains "whistle", "finish", "clap", "normal"
-	Position    Point     `json:"position"`
-	Additions   *Addition `json:"additions"`
+	ObjectName  ObjectKind `json:"objectName"` // "slider", "spinner", "circle"
+	Type        int        `json:"type"`       // Raw type bitflags
+	StartTime   int        `json:"startTime"`
+	EndTime     int        `json:"endTime"`     // Spinner only
+	RepeatCount int        `json:"repeatCount"` // Sliders only
+	PixelLength float64    `json:"pixelLength"` // Sliders only
+	Points      []Point    `json:"points"`      // Sliders only
+	Duration    int        `json:"duration"`    // Sliders only
+	CurveType   CurveType  `json:"curveType"`   // Sliders only, "catmull", "bezier", "linear", "pass-through"
+	EndPosition Point      `json:"endPosition"` // Sliders only
+	Edges       []Edge     `json:"edges"`
+	NewCombo    bool       `json:"newCombo"`
+	// Combo information, computed as the game does: spinners, objects following
+	// spinners and the first object always start a new combo.
+	ComboNumber     int       `json:"comboNumber"`     // Number shown on the object, from 1
+	ComboIndex      int       `json:"comboIndex"`      // Index of the combo colour, skips included
+	ComboColourSkip int       `json:"comboColourSkip"` // Number of combo colours skipped by a new combo
+	SoundTypes      HitSound  `json:"soundTypes"`      // Encoded as a list of "whistle", "finish", "clap", "normal"
+	Position        Point     `json:"position"`
+	Additions       *Addition `json:"additions"`
+	// Stacking information, computed from the beatmap's StackLeniency.
+	StackHeight     int   `json:"stackHeight"`
+	StackedPosition Point `json:"stackedPosition"`
 }
 
 type hitObjectSorter []HitObject
@@ -44,35 +53,10 @@ func sortHitObjects(b []HitObject) {
 
 // Edge represents a slider edge.
 type Edge struct {
-	SoundTypes []string  `json:"soundTypes"`
+	SoundTypes HitSound  `json:"soundTypes"`
 	Additions  *Addition `json:"addictions"`
 }
 
-// Gets sound types
-func parseSoundType(t int) []string {
-	a := make([]string, 0)
-	/**
-	 * sound type is a bitwise flag enum
-	 * 0 : normal
-	 * 2 : whistle
-	 * 4 : finish
-	 * 8 : clap
-	 */
-	if (t & 2) > 0 {
-		a = append(a, "whistle")
-	}
-	if (t & 4) > 0 {
-		a = append(a, "finish")
-	}
-	if (t & 8) > 0 {
-		a = append(a, "clap")
-	}
-	if len(a) == 0 {
-		a = append(a, "normal")
-	}
-	return a
-}
-
 func (b *Beatmap) parseHitObject(line string) (err error) {
 	h := HitObject{}
 	members := strings.Split(line, ",")
@@ -89,12 +73,13 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 		return
 	}
 	h.NewCombo = (objectType & 4) > 0
-	h.SoundTypes = make([]string, 0)
+	h.ComboColourSkip = (objectType >> 4) & 7
+	h.Type = objectType
 	h.Edges = make([]Edge, 0)
 	if h.Position, err = parsePoint(members[0], members[1]); err != nil {
 		return
 	}
-	h.SoundTypes = parseSoundType(soundType)
+	h.SoundTypes = HitSound(soundType)
 	/**
 	 * object type is a bitwise flag enum
 	 * 1: circle
@@ -103,7 +88,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 	 */
 	if (objectType & 1) > 0 {
 		// Circle
-		h.ObjectName = "circle"
+		h.ObjectName = ObjectCircle
 		b.NbCircles++
 		if len(members) > 5 {
 			if h.Additions, err = parseAddition(members[5]); err != nil {
@@ -111,7 +96,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 			}
 		}
 	} else if (objectType & 8) > 0 {
-		h.ObjectName = "spinner"
+		h.ObjectName = ObjectSpinner
 		b.NbSpinners++
 		if h.EndTime, err = strconv.Atoi(members[5]); err != nil {
 			return
@@ -122,7 +107,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 			}
 		}
 	} else if (objectType & 2) > 0 {
-		h.ObjectName = "slider"
+		h.ObjectName = ObjectSlider
 		b.NbSliders++
 		if h.RepeatCount, err = strconv.Atoi(members[6]); err != nil {
 			return
@@ -154,7 +139,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 			if typ, ok := curveTypes[points[0]]; ok {
 				h.CurveType = typ
 			} else {
-				h.CurveType = "unknown"
+				h.CurveType = CurveUnknown
 			}
 			for i := 1; i < len(points); i++ {
 				coords := strings.Split(points[i], ":")
@@ -186,9 +171,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 				if sound, err = strconv.Atoi(edgeSounds[j]); err != nil {
 					return
 				}
-				edge.SoundTypes = parseSoundType(sound)
-			} else {
-				edge.SoundTypes = []string{"normal"}
+				edge.SoundTypes = HitSound(sound)
 			}
 			h.Edges = append(h.Edges, edge)
 		}
@@ -201,7 +184,7 @@ func (b *Beatmap) parseHitObject(line string) (err error) {
 			h.EndPosition = h.Points[len(h.Points)-1]
 		}
 	} else {
-		h.ObjectName = "unknown"
+		h.ObjectName = ObjectUnknown
 	}
 	b.HitObjects = append(b.HitObjects, h)
 	return
@@ -0,0 +1,312 @@
+// Package osubin reads and writes the binary primitives used by osu!'s
+// file formats (.osr, osu!.db, collection.db and scores.db).
+// All numbers are little-endian.
+package osubin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrInvalidString is returned when a string does not start with 0x00 or 0x0b.
+var ErrInvalidString = errors.New("osubin: invalid string marker")
+
+// ErrOverflow is returned when a ULEB128 integer does not fit in 64 bits.
+var ErrOverflow = errors.New("osubin: ULEB128 integer overflows 64 bits")
+
+// ErrInvalidLength is returned when a byte count is negative.
+var ErrInvalidLength = errors.New("osubin: invalid length")
+
+// Number of 100ns ticks between 0001-01-01 and the Unix epoch.
+const epochTicks = 621355968000000000
+
+// Reader reads binary primitives from an underlying reader.
+type Reader struct {
+	r   *bufio.Reader
+	buf [8]byte
+}
+
+// NewReader creates a Reader reading from r.
+// If r is already a Reader, it is returned as is, so that nested
+// structures can be parsed from the same buffered stream.
+func NewReader(r io.Reader) *Reader {
+	if rd, ok := r.(*Reader); ok {
+		return rd
+	}
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read reads raw bytes, implementing io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func (r *Reader) read(n int) ([]byte, error) {
+	if _, err := io.ReadFull(r.r, r.buf[:n]); err != nil {
+		return nil, err
+	}
+	return r.buf[:n], nil
+}
+
+// Byte reads a single byte.
+func (r *Reader) Byte() (byte, error) {
+	return r.r.ReadByte()
+}
+
+// Bool reads a single byte, true if it is not zero.
+func (r *Reader) Bool() (bool, error) {
+	b, err := r.r.ReadByte()
+	return b != 0, err
+}
+
+// Short reads a 2-byte integer.
+func (r *Reader) Short() (int16, error) {
+	b, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.LittleEndian.Uint16(b)), nil
+}
+
+// Int reads a 4-byte integer.
+func (r *Reader) Int() (int32, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+// Long reads an 8-byte integer.
+func (r *Reader) Long() (int64, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+// Single reads a 4-byte floating point number.
+func (r *Reader) Single() (float32, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+// Double reads an 8-byte floating point number.
+func (r *Reader) Double() (float64, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// ULEB128 reads an unsigned LEB128 variable-length integer.
+func (r *Reader) ULEB128() (uint64, error) {
+	var (
+		v     uint64
+		shift uint
+	)
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+		if shift += 7; shift > 63 {
+			return 0, ErrOverflow
+		}
+	}
+}
+
+// String reads a string: either 0x00 for an empty string,
+// or 0x0b followed by the ULEB128 length and the UTF-8 bytes.
+func (r *Reader) String() (string, error) {
+	marker, err := r.r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch marker {
+	case 0x00:
+		return "", nil
+	case 0x0b:
+	default:
+		return "", ErrInvalidString
+	}
+	n, err := r.ULEB128()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.readN(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Bytes reads exactly n raw bytes.
+func (r *Reader) Bytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrInvalidLength
+	}
+	return r.readN(uint64(n))
+}
+
+// Reads n bytes into a buffer growing as data is read, so that a wrong length
+// in a truncated file gives io.ErrUnexpectedEOF instead of a huge allocation.
+func (r *Reader) readN(n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, ErrInvalidLength
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Time reads a date stored as Windows ticks (100ns since 0001-01-01 UTC).
+func (r *Reader) Time() (time.Time, error) {
+	ticks, err := r.Long()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return TicksToTime(ticks), nil
+}
+
+// TicksToTime converts Windows ticks to a time.
+func TicksToTime(ticks int64) time.Time {
+	ticks -= epochTicks
+	sec, rem := ticks/1e7, ticks%1e7
+	if rem < 0 {
+		sec, rem = sec-1, rem+1e7
+	}
+	return time.Unix(sec, rem*100).UTC()
+}
+
+// TimeToTicks converts a time to Windows ticks.
+func TimeToTicks(t time.Time) int64 {
+	return t.Unix()*1e7 + int64(t.Nanosecond())/100 + epochTicks
+}
+
+// Writer writes binary primitives to an underlying writer.
+// The first error encountered is kept and returned by Err,
+// and all following writes are ignored.
+type Writer struct {
+	w   io.Writer
+	buf [10]byte
+	err error
+}
+
+// NewWriter creates a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error encountered while writing.
+func (w *Writer) Err() error { return w.err }
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(b)
+}
+
+// Byte writes a single byte.
+func (w *Writer) Byte(b byte) {
+	w.buf[0] = b
+	w.write(w.buf[:1])
+}
+
+// Bool writes a single byte, 1 if v is true.
+func (w *Writer) Bool(v bool) {
+	if v {
+		w.Byte(1)
+	} else {
+		w.Byte(0)
+	}
+}
+
+// Short writes a 2-byte integer.
+func (w *Writer) Short(v int16) {
+	binary.LittleEndian.PutUint16(w.buf[:2], uint16(v))
+	w.write(w.buf[:2])
+}
+
+// Int writes a 4-byte integer.
+func (w *Writer) Int(v int32) {
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(v))
+	w.write(w.buf[:4])
+}
+
+// Long writes an 8-byte integer.
+func (w *Writer) Long(v int64) {
+	binary.LittleEndian.PutUint64(w.buf[:8], uint64(v))
+	w.write(w.buf[:8])
+}
+
+// Single writes a 4-byte floating point number.
+func (w *Writer) Single(v float32) {
+	binary.LittleEndian.PutUint32(w.buf[:4], math.Float32bits(v))
+	w.write(w.buf[:4])
+}
+
+// Double writes an 8-byte floating point number.
+func (w *Writer) Double(v float64) {
+	binary.LittleEndian.PutUint64(w.buf[:8], math.Float64bits(v))
+	w.write(w.buf[:8])
+}
+
+// ULEB128 writes an unsigned LEB128 variable-length integer.
+func (w *Writer) ULEB128(v uint64) {
+	n := 0
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		w.buf[n] = b
+		n++
+		if v == 0 {
+			break
+		}
+	}
+	w.write(w.buf[:n])
+}
+
+// String writes a string, with 0x00 for an empty string.
+func (w *Writer) String(s string) {
+	if len(s) == 0 {
+		w.Byte(0x00)
+		return
+	}
+	w.Byte(0x0b)
+	w.ULEB128(uint64(len(s)))
+	w.write([]byte(s))
+}
+
+// Bytes writes raw bytes.
+func (w *Writer) Bytes(b []byte) {
+	w.write(b)
+}
+
+// Time writes a date as Windows ticks.
+func (w *Writer) Time(t time.Time) {
+	w.Long(TimeToTicks(t))
+}
@@ -4,11 +4,14 @@
 // The output JSON has basically the same attributes with the Node.js implementation,
 // however there are key differences:
 //  - Empty additions are presented as null instead of an empty struct.
-//  - Unlisted properties (in Beatmap object) are stored in a struct under "OtherAttributes",
-//  some notable properties are "ComboX" and some other color properties.
+//  - Unlisted properties (in Beatmap object) are stored in a struct under "OtherAttributes".
+//  - Combo and slider colours are parsed into "colours" instead of being beatmap properties.
 package parser
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -49,5 +52,17 @@ func ParseString(str string) (b Beatmap, err error) {
 	if err != nil {
 		return b, err
 	}
+	sum := md5.Sum([]byte(str))
+	B.MD5 = hex.EncodeToString(sum[:])
 	return *B, nil
 }
+
+// ComputeMD5 computes the MD5 checksum of a beatmap file, as a hex string.
+// This is the checksum the game uses to identify beatmaps.
+func ComputeMD5(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
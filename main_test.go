@@ -1,10 +1,16 @@
 package parser
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"image/color"
 	"io/ioutil"
+	"math"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +42,928 @@ func TestAll(t *testing.T) {
 		t.Run(fmt.Sprintf("Test v%d", i), runTest(i))
 	}
 }
+
+func TestWithRate(t *testing.T) {
+	b, err := ParseFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Bookmarks = []int{3000}
+	fast, err := b.WithRate(1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fast.HitObjects) != len(b.HitObjects) {
+		t.Fatalf("Expected %d hit objects, got %d", len(b.HitObjects), len(fast.HitObjects))
+	}
+	last := b.HitObjects[len(b.HitObjects)-1]
+	if got, want := fast.HitObjects[len(fast.HitObjects)-1].StartTime, int(float64(last.StartTime)/1.5+0.5); got != want {
+		t.Errorf("Expected last object at %d, got %d", want, got)
+	}
+	if got, want := fast.TimingPoints[0].BeatLength, b.TimingPoints[0].BeatLength/1.5; got != want {
+		t.Errorf("Expected beat length %v, got %v", want, got)
+	}
+	if fast.TotalTime >= b.TotalTime {
+		t.Errorf("Expected total time to shrink, got %d (was %d)", fast.TotalTime, b.TotalTime)
+	}
+	if got, want := fast.Storyboard.Sprites[0].Commands[0].EndTime, int(float64(b.Storyboard.Sprites[0].Commands[0].EndTime)/1.5+0.5); got != want {
+		t.Errorf("Expected the first storyboard command to end at %d, got %d", want, got)
+	}
+	if len(fast.Bookmarks) != 1 || fast.Bookmarks[0] != 2000 {
+		t.Errorf("Expected the bookmark at 2000, got %v", fast.Bookmarks)
+	}
+	if fast.MD5 != "" {
+		t.Errorf("Expected the MD5 to be cleared, got %q", fast.MD5)
+	}
+	fast.Tags[0] = "changed"
+	fast.OtherAttributes["Changed"] = "1"
+	if b.HitObjects[0].StartTime == fast.HitObjects[0].StartTime || b.Bookmarks[0] != 3000 || b.Tags[0] == "changed" ||
+		b.OtherAttributes["Changed"] != "" || b.Storyboard.Sprites[0].Commands[0].EndTime == fast.Storyboard.Sprites[0].Commands[0].EndTime {
+		t.Error("Original beatmap was modified")
+	}
+	// Without objects the durations are scaled as they are
+	empty, err := (Beatmap{TotalTime: 90, DrainingTime: 61}).WithRate(1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.TotalTime != 60 || empty.DrainingTime != 40 {
+		t.Errorf("Expected durations 60 and 40, got %d and %d", empty.TotalTime, empty.DrainingTime)
+	}
+	for _, r := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if _, err := b.WithRate(r); err == nil {
+			t.Errorf("Expected an error for rate %v", r)
+		}
+	}
+}
+
+func TestReadOsz(t *testing.T) {
+	osu, err := ioutil.ReadFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string][]byte{
+		"keeno - Crack (Azure) [Insane].osu": osu,
+		"BG.jpg":                             []byte("bg"),
+		"Crack.mp3":                          []byte("audio"),
+		"soft-hitclap.wav":                   []byte("clap"),
+		"SB/title01.png":                     []byte("sprite"),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadOsz(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Files) != 5 || len(o.Beatmaps) != 1 || o.Beatmaps[0].Title != "Crack" {
+		t.Fatalf("Unexpected archive contents: %d files, %d beatmaps", len(o.Files), len(o.Beatmaps))
+	}
+	if _, ok := o.File(`SB\title01.png`); !ok {
+		t.Error("Expected to find the storyboard sprite")
+	}
+	a := o.Assets(o.Beatmaps[0].Beatmap)
+	if a.Audio == nil || a.Background == nil || a.Background.Name != "BG.jpg" || a.Video != nil {
+		t.Errorf("Unexpected assets %+v", a)
+	}
+	if len(a.Hitsounds) != 1 || a.Hitsounds[0].Name != "soft-hitclap.wav" {
+		t.Errorf("Unexpected hitsounds %+v", a.Hitsounds)
+	}
+	if len(a.Storyboard) != 1 || a.Storyboard[0].Name != "SB/title01.png" {
+		t.Errorf("Unexpected storyboard files %+v", a.Storyboard)
+	}
+}
+
+func TestWriteOsz(t *testing.T) {
+	osu, err := ioutil.ReadFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := NewOszBuilder()
+	if err := builder.AddBeatmap("keeno - Crack (Azure) [Insane].osu", osu); err != nil {
+		t.Fatal(err)
+	}
+	builder.AddAsset("crack.mp3", []byte("audio"))
+	var buf bytes.Buffer
+	if err := builder.Write(&buf); err == nil {
+		t.Error("Expected the missing background and storyboard to be reported")
+	} else if missing, ok := err.(MissingFilesError); !ok || len(missing) != 5 || missing[0] != "bg.jpg" || missing[1] != `SB\title01.png` {
+		t.Errorf("Unexpected error %v", err)
+	}
+	builder.AddAsset("bg.jpg", []byte("bg"))
+	for i := 1; i <= 4; i++ {
+		builder.AddAsset(fmt.Sprintf("SB/title%02d.png", i), []byte("sprite"))
+	}
+	if err := builder.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadOsz(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Files) != 7 || len(o.Beatmaps) != 1 {
+		t.Errorf("Unexpected archive contents: %d files, %d beatmaps", len(o.Files), len(o.Beatmaps))
+	}
+	// A replaced difficulty no longer references its files
+	builder = NewOszBuilder()
+	if err := builder.AddBeatmap("keeno - Crack (Azure) [Insane].osu", osu); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.AddBeatmap("KEENO - Crack (Azure) [Insane].osu", []byte(testBeatmapHeader)); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Validate(); err != nil {
+		t.Errorf("Expected the replaced beatmap's files not to be required, got %v", err)
+	}
+	buf.Reset()
+	if err := builder.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if o, err = ReadOsz(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Files) != 1 || len(o.Beatmaps) != 1 {
+		t.Errorf("Expected a single beatmap, got %d files and %d beatmaps", len(o.Files), len(o.Beatmaps))
+	}
+}
+
+func TestMD5(t *testing.T) {
+	b, err := ParseFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if sum, err := ComputeMD5(f); err != nil {
+		t.Error(err)
+	} else if len(sum) != 32 || sum != b.MD5 {
+		t.Errorf("Expected checksum %q, got %q", sum, b.MD5)
+	}
+}
+
+func TestStoryboard(t *testing.T) {
+	b, err := ParseString(`osu file format v14
+
+[Events]
+Animation,Foreground,BottomCentre,"sb/anim.png",320,480,4,50,LoopOnce
+ S,0,1000,,0.5
+ M,1,1000,2000,0,0,100,100,200,0
+ L,3000,2
+  F,0,0,500,0,1
+ T,HitSoundClap,0,10000
+  R,0,0,100,0,3.14
+ P,0,1000,1000,H
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Storyboard.Sprites) != 1 {
+		t.Fatalf("Expected 1 sprite, got %d", len(b.Storyboard.Sprites))
+	}
+	s := b.Storyboard.Sprites[0]
+	if !s.IsAnimation || s.Layer != LayerForeground || s.Origin != OriginBottomCentre || s.FrameCount != 4 || s.LoopType != LoopOnce {
+		t.Errorf("Unexpected animation %+v", s)
+	}
+	// The chained move is split in two
+	if len(s.Commands) != 4 {
+		t.Fatalf("Expected 4 commands, got %+v", s.Commands)
+	}
+	if c := s.Commands[0]; c.EndTime != 1000 || c.EndValues[0] != 0.5 {
+		t.Errorf("Unexpected scale command %+v", c)
+	}
+	if c := s.Commands[2]; c.Easing != 1 || c.StartTime != 2000 || c.EndTime != 3000 || c.StartValues[0] != 100 || c.EndValues[0] != 200 {
+		t.Errorf("Unexpected chained move %+v", c)
+	}
+	if c := s.Commands[3]; c.Type != CommandParameter || c.Parameter != "H" {
+		t.Errorf("Unexpected parameter command %+v", c)
+	}
+	if len(s.Loops) != 1 || s.Loops[0].LoopCount != 2 || len(s.Loops[0].Commands) != 1 {
+		t.Errorf("Unexpected loops %+v", s.Loops)
+	}
+	if len(s.Triggers) != 1 || s.Triggers[0].Name != "HitSoundClap" || len(s.Triggers[0].Commands) != 1 {
+		t.Errorf("Unexpected triggers %+v", s.Triggers)
+	}
+}
+
+func TestParseStoryboard(t *testing.T) {
+	s, err := ParseStoryboard(strings.NewReader(`[Variables]
+$s=Sprite,Background,TopLeft
+$sx=0.25
+[Events]
+//Storyboard Layer 0 (Background)
+$s,"bg.png",0,0
+ S,0,0,1000,$sx,0.5
+Sample,2000,Pass,"applause.wav",80
+5,1000,0,"intro.wav"
+Animation,Foreground,Centre,"sb/anim.jpg",320,240,2,100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Sprites) != 2 || s.Sprites[0].Layer != LayerBackground || len(s.Sprites[0].Commands) != 1 {
+		t.Fatalf("Unexpected storyboard %+v", s)
+	}
+	if c := s.Sprites[0].Commands[0]; c.StartValues[0] != 0.25 || c.EndValues[0] != 0.5 {
+		t.Errorf("Expected variables to be substituted, got %+v", c)
+	}
+	if len(s.Samples) != 2 || s.Samples[0].Filepath != "intro.wav" || s.Samples[0].Volume != 100 ||
+		s.Samples[1].Layer != LayerPass || s.Samples[1].Volume != 80 {
+		t.Errorf("Unexpected samples %+v", s.Samples)
+	}
+	if names := s.Filenames(); strings.Join(names, ",") != "bg.png,sb/anim0.jpg,sb/anim1.jpg,intro.wav,applause.wav" {
+		t.Errorf("Unexpected filenames %v", names)
+	}
+	b, err := ParseFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := b.Storyboard.Merge(s)
+	if n := len(b.Storyboard.Sprites); len(merged.Sprites) != n+2 || merged.Sprites[n].Filepath != "bg.png" {
+		t.Errorf("Expected the .osb sprite after the difficulty's %d sprites", n)
+	}
+}
+
+func TestMalformedStoryboard(t *testing.T) {
+	data, err := ioutil.ReadFile("testfiles/v14.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := `3,100,a,0,0
+3,200,255,0,0
+//Storyboard Layer 0 (Background)
+Sprite,Background,Centre,"short.png"
+ F,0,0,1000,0,1
+Sprite,Background,Centre,"ok.png",320,240
+ F,0,1000,2000,0,1,
+ M,0,0,1000,1,2,3
+ S,0,0,1000
+ M,OutQuad,0,1000,0,0,1,1
+ L,x,2
+  F,0,0,100,1
+ R,0,0,1000,0,1
+`
+	str := strings.Replace(string(data), "//Storyboard Layer 0 (Background)\r\n", strings.Replace(events, "\n", "\r\n", -1), 1)
+	b, err := ParseString(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.HitObjects) == 0 {
+		t.Error("Expected the hit objects to be parsed")
+	}
+	if len(b.BackgroundColours) != 1 || b.BackgroundColours[0].Time != 200 {
+		t.Errorf("Expected only the valid background colour, got %+v", b.BackgroundColours)
+	}
+	sb := b.Storyboard
+	if len(sb.Sprites) != 1 || sb.Sprites[0].Filepath != "ok.png" {
+		t.Fatalf("Expected only the valid sprite, got %+v", sb.Sprites)
+	}
+	cmds := sb.Sprites[0].Commands
+	if len(cmds) != 2 || cmds[0].Type != CommandFade || cmds[0].EndValues[0] != 1 || cmds[1].Type != CommandRotate {
+		t.Errorf("Expected the valid commands only, got %+v", cmds)
+	}
+	if len(sb.Sprites[0].Loops) != 0 {
+		t.Errorf("Expected the invalid loop to be skipped, got %+v", sb.Sprites[0].Loops)
+	}
+	if _, err = ParseStoryboardStrict(strings.NewReader("[Events]\r\n" + events)); err == nil {
+		t.Error("Expected strict parsing to fail")
+	}
+}
+
+func TestSpriteState(t *testing.T) {
+	s, err := ParseStoryboard(strings.NewReader(`[Events]
+Sprite,Foreground,Centre,"a.png",320,240
+ F,0,1000,2000,0,1
+ M,1,1000,2000,0,0,100,200
+ MX,0,1500,,50
+ V,0,1000,,2,3
+ C,0,1000,,255,128,0
+ P,0,1000,,H
+ L,3000,2
+  R,0,0,100,0,1
+Sprite,Background,Centre,"hidden.png",320,240
+ F,0,0,1000,0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Sprites[0].StateAt(500); st.Visible || st.Opacity != 0 || st.Position.X != 0 {
+		t.Errorf("Expected the first commands' start values before the sprite starts, got %+v", st)
+	}
+	st := s.SpriteStateAt(1250)[0]
+	// Easing out: the sprite has moved more than a quarter of the way
+	if !st.Visible || st.Opacity != 0.25 || st.Position.Y <= 50 || st.Position.Y >= 200 {
+		t.Errorf("Unexpected state at 1250: %+v", st)
+	}
+	if st.Scale.X != 2 || st.Scale.Y != 3 || st.Colour.G != 128 || !st.FlipH || st.Additive {
+		t.Errorf("Unexpected state at 1250: %+v", st)
+	}
+	// The later MX command overrides the move
+	if st := s.Sprites[0].StateAt(1750); st.Position.X != 50 {
+		t.Errorf("Expected the MX command to override the move, got %+v", st.Position)
+	}
+	// The loop runs twice, then the sprite ends
+	if st := s.Sprites[0].StateAt(3150); st.Rotation != 0.5 {
+		t.Errorf("Expected the second loop iteration, got rotation %v", st.Rotation)
+	}
+	if start, end, ok := s.Sprites[0].Lifetime(); !ok || start != 1000 || end != 3200 {
+		t.Errorf("Unexpected lifetime %v-%v", start, end)
+	}
+	if s.Sprites[0].NeverVisible() || !s.Sprites[1].NeverVisible() {
+		t.Error("Expected only the second sprite to never be visible")
+	}
+	if EasingQuadOut.Apply(0.5) != 0.75 || EasingBounceOut.Apply(1) != 1 || EasingLinear.Apply(0.3) != 0.3 {
+		t.Error("Unexpected easing values")
+	}
+	for _, test := range []struct{ T, Value float64 }{{0.25, 0.011969444423734}, {0.4, -0.117461577598239}, {0.5, 0.5}, {0.75, 0.988030555576266}} {
+		if got := EasingElasticInOut.Apply(test.T); math.Abs(got-test.Value) > 1e-9 {
+			t.Errorf("Expected the elastic in-out easing to be %v at %v, got %v", test.Value, test.T, got)
+		}
+	}
+}
+
+func TestWriteStoryboard(t *testing.T) {
+	b, err := ParseFile("testfiles/v10.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := b.Storyboard
+	sb.Sprites = append(sb.Sprites, Sprite{
+		Layer: LayerOverlay, Filepath: "a.png", IsAnimation: true, FrameCount: 2, FrameDelay: 16.5,
+		Commands: []Command{{Type: CommandParameter, StartTime: 10, EndTime: 10, StartValues: []float64{}, EndValues: []float64{}, Parameter: "A"}},
+		Loops:    []Loop{{StartTime: 100, LoopCount: 3, Commands: []Command{{Type: CommandRotate, EndTime: 50, StartValues: []float64{0}, EndValues: []float64{-1.5}}}}},
+		Triggers: []Trigger{{Name: "Passing", EndTime: 1000, GroupNumber: 1, Commands: []Command{{Type: CommandFade, StartValues: []float64{1}, EndValues: []float64{1}}}}},
+	})
+	sb.Samples = append(sb.Samples, Sample{Time: 5, Layer: LayerFail, Filepath: "boo.wav", Volume: 70})
+	parsed, err := ParseStoryboard(bytes.NewReader(sb.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, sb) {
+		t.Errorf("Storyboard changed after writing:\n%s", sb.Bytes())
+	}
+
+	// The whole [Events] section of a beatmap
+	b.VideoFilename, b.VideoOffset = "video.avi", -200
+	b.BackgroundColours = []BackgroundColour{{Time: 100, Colour: color.RGBA{163, 162, 255, 255}}}
+	var events bytes.Buffer
+	if err := b.WriteEvents(&events); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := ParseString("osu file format v14\r\n\r\n[Events]\r\n" + events.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.BgFilename != b.BgFilename || rebuilt.VideoFilename != b.VideoFilename || rebuilt.VideoOffset != b.VideoOffset ||
+		!reflect.DeepEqual(rebuilt.BreakTimes, b.BreakTimes) || !reflect.DeepEqual(rebuilt.BackgroundColours, b.BackgroundColours) ||
+		!reflect.DeepEqual(rebuilt.Storyboard, b.Storyboard) {
+		t.Errorf("Events changed after writing:\n%s", events.String())
+	}
+
+	s, err := ParseStoryboard(strings.NewReader(`[Events]
+Sprite,Foreground,Centre,"a.png",320.00001,240
+ F,0,0,1000,1
+ F,0,500,2000,1
+ M,0,0,100,0,0,10,10
+ M,0,100,200,10,10,20,20.123456
+Sprite,Foreground,Centre,"hidden.png",320,240
+ F,0,0,1000,0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := s.Optimize(2)
+	if len(o.Sprites) != 1 {
+		t.Fatalf("Expected the hidden sprite to be removed, got %d sprites", len(o.Sprites))
+	}
+	if o.Sprites[0].X != 320 || len(o.Sprites[0].Commands) != 3 || o.Sprites[0].Commands[0].EndTime != 2000 {
+		t.Errorf("Unexpected optimised sprite %+v", o.Sprites[0])
+	}
+	expected := "Sprite,Foreground,Centre,\"a.png\",320,240\r\n F,0,0,2000,1\r\n M,0,0,100,0,0,10,10,20,20.12\r\n"
+	if out := string(o.Bytes()); !strings.Contains(out, expected) {
+		t.Errorf("Expected %q in:\n%s", expected, out)
+	}
+}
+
+func TestColours(t *testing.T) {
+	b, err := ParseString("osu file format v14\n\n[Colours]\nCombo2 : 0,0,255\nCombo1 : 255,0,0\nSliderBody : 10,20,30,128\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Colours
+	if len(c.ComboColours) != 2 || c.ComboColours[0].R != 255 || c.ComboColours[1].B != 255 {
+		t.Errorf("Unexpected combo colours %v", c.ComboColours)
+	}
+	if c.SliderBody == nil || c.SliderBody.A != 128 || c.SliderBorder != nil || len(b.OtherAttributes) != 0 {
+		t.Errorf("Unexpected slider colours %+v", c)
+	}
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[Colours]\r\nCombo1 : 255,0,0\r\nCombo2 : 0,0,255\r\nSliderBody : 10,20,30,128\r\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+	for _, invalid := range []string{"255,0", "256,0,0", "a,b,c"} {
+		if _, err := ParseString("[Colours]\nCombo1 : " + invalid + "\n"); err == nil {
+			t.Errorf("Expected colour %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestCombos(t *testing.T) {
+	b, err := ParseString(`osu file format v14
+
+[Colours]
+Combo1 : 255,0,0
+Combo2 : 0,255,0
+Combo3 : 0,0,255
+
+[HitObjects]
+100,100,1000,1,0,0:0:0:0:
+100,100,1100,1,0,0:0:0:0:
+100,100,1200,37,0,0:0:0:0:
+256,192,1300,28,0,1500,0:0:0:0:
+100,100,1600,1,0,0:0:0:0:
+100,100,1700,1,0,0:0:0:0:
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ Number, Index, Skip int }{{1, 0, 0}, {2, 0, 0}, {1, 3, 2}, {2, 3, 1}, {1, 5, 0}, {2, 5, 0}}
+	for i, e := range expected {
+		h := b.HitObjects[i]
+		if h.ComboNumber != e.Number || h.ComboIndex != e.Index || h.ComboColourSkip != e.Skip {
+			t.Errorf("Object %d: expected %+v, got number %d, index %d, skip %d", i, e, h.ComboNumber, h.ComboIndex, h.ComboColourSkip)
+		}
+	}
+	if c, ok := b.ComboColour(b.HitObjects[5]); !ok || c.B != 255 {
+		t.Errorf("Unexpected combo colour %v", c)
+	}
+}
+
+func TestEditor(t *testing.T) {
+	b, err := ParseFile("testfiles/v14.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Bookmarks) != 35 || b.Bookmarks[0] != 7256 || b.DistanceSpacing != 1.5 || b.BeatDivisor != 8 || b.TimelineZoom != 1 {
+		t.Errorf("Unexpected editor state %+v", b.Editor)
+	}
+	if _, ok := b.OtherAttributes["Bookmarks"]; ok {
+		t.Error("Expected bookmarks to be removed from the other attributes")
+	}
+	b, err = ParseFile("testfiles/v3.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.DistanceSpacing != 1 || b.BeatDivisor != 4 || b.GridSize != 32 || len(b.Bookmarks) != 0 {
+		t.Errorf("Expected default editor state, got %+v", b.Editor)
+	}
+}
+
+func TestGeneral(t *testing.T) {
+	b, err := ParseString(`osu file format v14
+
+[General]
+SampleSet: Drum
+Countdown: 3
+CountdownOffset: 2
+EpilepsyWarning: 1
+OverlayPosition: Above
+StoryFireInFront: 0
+SkinPreference: Default
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.SampleSet != SampleSetDrum || b.Countdown != CountdownDouble || b.CountdownOffset != 2 || !b.EpilepsyWarning ||
+		b.OverlayPosition != OverlayAbove || b.StoryFireInFront || b.SkinPreference != "Default" || len(b.OtherAttributes) != 0 {
+		t.Errorf("Unexpected general settings %+v", b)
+	}
+	if b, err = ParseString("osu file format v14\n"); err != nil {
+		t.Fatal(err)
+	}
+	if b.SampleSet != SampleSetNormal || b.Countdown != CountdownNormal || !b.StoryFireInFront || b.OverlayPosition != OverlayNoChange {
+		t.Errorf("Unexpected default general settings %+v", b)
+	}
+	var s SampleSet
+	if data, err := json.Marshal(SampleSetSoft); err != nil || string(data) != `"Soft"` {
+		t.Errorf("Unexpected JSON %s", data)
+	} else if err := json.Unmarshal(data, &s); err != nil || s != SampleSetSoft {
+		t.Errorf("Expected Soft, got %v", s)
+	}
+	for _, set := range []string{"Unknown", "None", "9", "3"} {
+		if b, err = ParseString("osu file format v14\n\n[General]\nSampleSet: " + set + "\n"); err != nil {
+			t.Fatal(err)
+		}
+		if b.SampleSet != SampleSetNormal {
+			t.Errorf("Expected sample set %q to fall back to Normal, got %v", set, b.SampleSet)
+		}
+	}
+	if data, err := json.Marshal(CountdownHalf); err != nil || string(data) != "2" || CountdownHalf.String() != "Half" {
+		t.Errorf("Expected the half countdown to be encoded as 2, got %s", data)
+	}
+}
+
+func TestHitObjectTypes(t *testing.T) {
+	b, err := ParseFile("testfiles/v14.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range b.HitObjects {
+		if h.ObjectName == ObjectSlider && (h.Type&2 == 0 || h.CurveType == CurveNone) {
+			t.Errorf("Unexpected slider %+v", h)
+		}
+		if h.ObjectName == ObjectCircle && (h.Type&1 == 0 || h.CurveType != CurveNone) {
+			t.Errorf("Unexpected circle %+v", h)
+		}
+	}
+	if s := (HitSoundNormal | HitSoundWhistle | HitSoundClap); s.String() != "whistle,clap" || !s.Has(HitSoundClap) || s.Has(HitSoundFinish) {
+		t.Errorf("Unexpected hitsound %v", s)
+	}
+	if HitSound(0).String() != "normal" || CurvePassThrough.String() != "pass-through" || ObjectSpinner.String() != "spinner" {
+		t.Error("Unexpected names")
+	}
+	// Typed values survive a JSON round trip
+	v := struct {
+		Kind      ObjectKind
+		Curve     CurveType
+		Sound     HitSound
+		Additions Addition
+	}{ObjectSlider, CurveCatmull, HitSoundFinish | HitSoundClap, Addition{Sample: SampleSetSoft, HitsoundVolume: 40}}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Sound":["finish","clap"]`) || !strings.Contains(string(data), `"sample":"soft","additionalSample":""`) {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+	w := v
+	w.Kind, w.Curve, w.Sound, w.Additions = 0, 0, 0, Addition{}
+	if err := json.Unmarshal(data, &w); err != nil {
+		t.Fatal(err)
+	} else if w != v {
+		t.Errorf("Expected %+v after a JSON round trip, got %+v", v, w)
+	}
+	var a Addition
+	if err := json.Unmarshal([]byte(`{"sample":"rock","additionalSample":""}`), &a); err == nil {
+		t.Error("Expected an error for an unknown sample set")
+	}
+	if err := json.Unmarshal([]byte(`{"Kind":"7"}`), &w); err == nil {
+		t.Errorf("Expected an error for a numeric object kind, got %v", w.Kind)
+	}
+	if err := json.Unmarshal([]byte(`{"Curve":"7"}`), &w); err == nil {
+		t.Errorf("Expected an error for a numeric curve type, got %v", w.Curve)
+	}
+}
+
+// A minimal osu!standard beatmap with 100 osu!pixels per 500ms beat.
+const testBeatmapHeader = `osu file format v14
+
+[General]
+Mode: 0
+
+[Difficulty]
+HPDrainRate:5
+CircleSize:4
+OverallDifficulty:5
+ApproachRate:9
+SliderMultiplier:1
+SliderTickRate:1
+
+[TimingPoints]
+0,500,4,2,0,100,1,0
+
+[HitObjects]
+`
+
+func closeTo(a, b Point) bool {
+	return math.Abs(a.X-b.X) < 0.5 && math.Abs(a.Y-b.Y) < 0.5
+}
+
+func TestSliderPath(t *testing.T) {
+	for _, test := range []struct {
+		Curve  CurveType
+		Points []Point
+		Length float64
+		Mid    Point
+		End    Point
+	}{
+		{CurveLinear, []Point{{100, 100}, {300, 100}}, 200, Point{200, 100}, Point{300, 100}},
+		{CurveLinear, []Point{{100, 100}, {300, 100}}, 100, Point{150, 100}, Point{200, 100}},
+		{CurveLinear, []Point{{100, 100}, {300, 100}}, 300, Point{250, 100}, Point{400, 100}},
+		{CurveLinear, []Point{{0, 0}, {100, 0}, {100, 100}}, 0, Point{100, 0}, Point{100, 100}},
+		{CurveBezier, []Point{{100, 100}, {200, 200}, {300, 100}}, 0, Point{200, 150}, Point{300, 100}},
+		{CurveBezier, []Point{{0, 0}, {100, 0}, {100, 0}, {100, 100}}, 0, Point{100, 0}, Point{100, 100}},
+		{CurvePassThrough, []Point{{0, 0}, {100, 100}, {200, 0}}, 0, Point{100, 100}, Point{200, 0}},
+		{CurvePassThrough, []Point{{0, 0}, {100, 0}, {200, 0}}, 0, Point{100, 0}, Point{200, 0}},
+		{CurveCatmull, []Point{{0, 0}, {100, 0}, {200, 0}}, 0, Point{100, 0}, Point{200, 0}},
+	} {
+		h := HitObject{ObjectName: ObjectSlider, CurveType: test.Curve, Position: test.Points[0], Points: test.Points, PixelLength: test.Length}
+		p := h.Path()
+		if got := p.PositionAt(0); !closeTo(got, test.Points[0]) {
+			t.Errorf("%s %v: expected the path to start at %v, got %v", test.Curve, test.Points, test.Points[0], got)
+		}
+		if got := p.PositionAt(0.5); !closeTo(got, test.Mid) {
+			t.Errorf("%s %v: expected the middle at %v, got %v", test.Curve, test.Points, test.Mid, got)
+		}
+		if got := p.PositionAt(1); !closeTo(got, test.End) {
+			t.Errorf("%s %v: expected the end at %v, got %v", test.Curve, test.Points, test.End, got)
+		}
+	}
+	// Catmull curves go through every control point
+	h := HitObject{ObjectName: ObjectSlider, CurveType: CurveCatmull, Points: []Point{{0, 0}, {100, 100}, {200, 0}}}
+	found := false
+	for _, pt := range h.Path().Points {
+		found = found || closeTo(pt, Point{100, 100})
+	}
+	if !found {
+		t.Error("Expected the catmull curve to go through its control point")
+	}
+	if got := (HitObject{ObjectName: ObjectCircle, Position: Point{10, 20}}).Path().PositionAt(0.5); got != (Point{10, 20}) {
+		t.Errorf("Expected a circle's path to be its position, got %v", got)
+	}
+}
+
+func TestSliderEvents(t *testing.T) {
+	b, err := ParseString(testBeatmapHeader + "100,100,1000,2,0,L|300:100,2,200\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := b.HitObjects[0]
+	if h.EndTime != 3000 {
+		t.Fatalf("Expected the slider to end at 3000, got %d", h.EndTime)
+	}
+	want := []SliderEvent{
+		{SliderHead, 1000, 0, 0},
+		{SliderTick, 1500, 0.5, 0},
+		{SliderRepeat, 2000, 1, 0},
+		{SliderTick, 2500, 0.5, 1},
+		{SliderTail, 3000, 0, 1},
+	}
+	if got := b.SliderEvents(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected events %+v, got %+v", want, got)
+	}
+	if got := h.LegacyTailTime(); got != 2964 {
+		t.Errorf("Expected the legacy tail at 2964, got %v", got)
+	}
+	for _, test := range []struct{ Time, Progress float64 }{{1000, 0}, {1500, 0.5}, {2000, 1}, {2250, 0.75}, {3000, 0}} {
+		if got := h.ProgressAt(test.Time); math.Abs(got-test.Progress) > 1e-9 {
+			t.Errorf("Expected progress %v at %v, got %v", test.Progress, test.Time, got)
+		}
+	}
+	// Ticks keep their positions on reversed spans: 100px and 200px along a 250px path
+	b, err = ParseString(testBeatmapHeader + "100,100,1000,2,0,L|350:100,2,250\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []SliderEvent{
+		{SliderHead, 1000, 0, 0},
+		{SliderTick, 1500, 0.4, 0},
+		{SliderTick, 2000, 0.8, 0},
+		{SliderRepeat, 2250, 1, 0},
+		{SliderTick, 2500, 0.8, 1},
+		{SliderTick, 3000, 0.4, 1},
+		{SliderTail, 3500, 0, 1},
+	}
+	got := b.SliderEvents(b.HitObjects[0])
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %+v, got %+v", want, got)
+	}
+	for i, e := range got {
+		if e.Type != want[i].Type || e.Span != want[i].Span ||
+			math.Abs(e.Time-want[i].Time) > 1e-6 || math.Abs(e.Progress-want[i].Progress) > 1e-9 {
+			t.Errorf("Expected event %d to be %+v, got %+v", i, want[i], e)
+		}
+	}
+	// Short sliders have their tail checked halfway
+	b, err = ParseString(testBeatmapHeader + "100,100,1000,2,0,L|110:100,1,10\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := b.HitObjects[0]; h.LegacyTailTime() != 1025 || len(b.SliderEvents(h)) != 2 {
+		t.Errorf("Expected a tail at 1025 and no ticks, got %v and %+v", h.LegacyTailTime(), b.SliderEvents(h))
+	}
+	if events := b.SliderEvents(HitObject{ObjectName: ObjectCircle}); events != nil {
+		t.Errorf("Expected no events for a circle, got %+v", events)
+	}
+}
+
+func TestWithMods(t *testing.T) {
+	b, err := ParseString(testBeatmapHeader + "100,100,1000,1,0\r\n200,50,2000,2,0,B|250:100|300:50,1,150\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr := b.WithMods(ModHardRock)
+	if hr.CircleSize != 5.2 || hr.ApproachRate != 10 || hr.OverallDifficulty != 7 || hr.HPDrainRate != 7 {
+		t.Errorf("Unexpected HardRock stats CS%v AR%v OD%v HP%v", hr.CircleSize, hr.ApproachRate, hr.OverallDifficulty, hr.HPDrainRate)
+	}
+	if got := hr.HitObjects[0].Position; got != (Point{100, 284}) {
+		t.Errorf("Expected the circle to be flipped to (100, 284), got %v", got)
+	}
+	if got, want := hr.HitObjects[1].Points, []Point{{200, 334}, {250, 284}, {300, 334}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the slider points to be flipped to %v, got %v", want, got)
+	}
+	if got, want := hr.HitObjects[1].Path().PositionAt(1), b.HitObjects[1].Path().PositionAt(1); !closeTo(got, Point{want.X, playfieldHeight - want.Y}) {
+		t.Errorf("Expected the slider end to be flipped from %v, got %v", want, got)
+	}
+	if b.HitObjects[0].Position != (Point{100, 100}) || b.HitObjects[1].Points[1] != (Point{250, 100}) {
+		t.Error("Original beatmap was modified")
+	}
+	ez := b.WithMods(ModEasy)
+	if ez.CircleSize != 2 || ez.ApproachRate != 4.5 || ez.OverallDifficulty != 2.5 || ez.HPDrainRate != 2.5 {
+		t.Errorf("Unexpected Easy stats CS%v AR%v OD%v HP%v", ez.CircleSize, ez.ApproachRate, ez.OverallDifficulty, ez.HPDrainRate)
+	}
+	if ez.HitObjects[0].Position != b.HitObjects[0].Position {
+		t.Error("Expected Easy not to flip the hit objects")
+	}
+	if dt := b.WithMods(ModDoubleTime | ModHidden); !reflect.DeepEqual(dt, b) {
+		t.Error("Expected other mods not to change the beatmap")
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	for _, test := range []struct {
+		Mode    int
+		OD      float64
+		Windows HitWindows
+	}{
+		{0, 0, HitWindows{Hit300: 80, Hit100: 140, Hit50: 200, Miss: 400}},
+		{0, 5, HitWindows{Hit300: 50, Hit100: 100, Hit50: 150, Miss: 400}},
+		{0, 8, HitWindows{Hit300: 32, Hit100: 76, Hit50: 120, Miss: 400}},
+		{0, 10, HitWindows{Hit300: 20, Hit100: 60, Hit50: 100, Miss: 400}},
+		{1, 0, HitWindows{Hit300: 50, Hit100: 120, Miss: 135}},
+		{1, 5, HitWindows{Hit300: 35, Hit100: 80, Miss: 95}},
+		{1, 10, HitWindows{Hit300: 20, Hit100: 50, Miss: 70}},
+		{2, 5, HitWindows{}},
+		{3, 0, HitWindows{HitMax: 16.5, Hit300: 64.5, Hit200: 97.5, Hit100: 127.5, Hit50: 151.5, Miss: 188.5}},
+		{3, 7.5, HitWindows{HitMax: 16.5, Hit300: 41.5, Hit200: 74.5, Hit100: 104.5, Hit50: 128.5, Miss: 165.5}},
+		{3, 8, HitWindows{HitMax: 16.5, Hit300: 40.5, Hit200: 73.5, Hit100: 103.5, Hit50: 127.5, Miss: 164.5}},
+		{3, 10, HitWindows{HitMax: 16.5, Hit300: 34.5, Hit200: 67.5, Hit100: 97.5, Hit50: 121.5, Miss: 158.5}},
+	} {
+		b := Beatmap{Mode: test.Mode, OverallDifficulty: test.OD}
+		if got := b.HitWindows(); got != test.Windows {
+			t.Errorf("Mode %d OD%v: expected windows %+v, got %+v", test.Mode, test.OD, test.Windows, got)
+		}
+	}
+	// Converted to osu!mania
+	for _, test := range []struct {
+		OD             float64
+		Hit300, Hit200 float64
+	}{{0, 47.5, 77.5}, {4, 47.5, 77.5}, {4.5, 47.5, 77.5}, {5, 34.5, 67.5}, {10, 34.5, 67.5}} {
+		want := HitWindows{HitMax: 16.5, Hit300: test.Hit300, Hit200: test.Hit200, Hit100: 97.5, Hit50: 121.5, Miss: 158.5}
+		if got := (Beatmap{OverallDifficulty: test.OD}).ManiaHitWindows(); got != want {
+			t.Errorf("Convert OD%v: expected windows %+v, got %+v", test.OD, want, got)
+		}
+	}
+	for _, test := range []struct{ AR, Preempt, FadeIn float64 }{
+		{0, 1800, 1200}, {5, 1200, 800}, {9, 600, 400}, {10, 450, 300},
+	} {
+		b := Beatmap{ApproachRate: test.AR}
+		if b.Preempt() != test.Preempt || b.FadeIn() != test.FadeIn {
+			t.Errorf("AR%v: expected preempt %v and fade-in %v, got %v and %v", test.AR, test.Preempt, test.FadeIn, b.Preempt(), b.FadeIn())
+		}
+	}
+	for _, test := range []struct{ CS, Radius float64 }{{0, 54.4}, {4, 36.48}, {5, 32}, {7, 23.04}, {10, 9.6}} {
+		if got := (Beatmap{CircleSize: test.CS}).CircleRadius(); math.Abs(got-test.Radius) > 1e-9 {
+			t.Errorf("CS%v: expected radius %v, got %v", test.CS, test.Radius, got)
+		}
+	}
+	// Old formats without an approach rate use the overall difficulty
+	b, err := ParseFile("testfiles/v5.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.HasApproachRate || b.Preempt() != (Beatmap{ApproachRate: b.OverallDifficulty}).Preempt() {
+		t.Errorf("Expected the preempt of OD%v, got %v", b.OverallDifficulty, b.Preempt())
+	}
+	// So do malformed approach rates
+	if b, err = ParseString("osu file format v14\n[Difficulty]\nOverallDifficulty:7\nApproachRate:x\n"); err != nil {
+		t.Fatal(err)
+	}
+	if b.HasApproachRate || b.ApproachRate != 7 {
+		t.Errorf("Expected AR7 from a malformed approach rate, got AR%v (present: %v)", b.ApproachRate, b.HasApproachRate)
+	}
+}
+
+func TestMods(t *testing.T) {
+	for _, test := range []struct {
+		Mods           Mods
+		String         string
+		Score, ScoreV2 float64
+		Rate           float64
+	}{
+		{0, "NM", 1, 1, 1},
+		{ModHidden | ModHardRock, "HDHR", 1.06 * 1.06, 1.06 * 1.1, 1},
+		{ModHidden | ModDoubleTime, "HDDT", 1.06 * 1.12, 1.06 * 1.2, 1.5},
+		{ModDoubleTime | ModNightcore, "NC", 1.12, 1.2, 1.5},
+		{ModSuddenDeath | ModPerfect, "PF", 1, 1, 1},
+		{ModNoFail | ModEasy | ModHalfTime, "NFEZHT", 0.5 * 0.5 * 0.3, 0.5 * 0.3, 0.75},
+		{ModFlashlight | ModSpunOut, "FLSO", 1.12 * 0.9, 1.12 * 0.9, 1},
+		{ModRelax, "RX", 0, 0, 1},
+		{ModKey4 | ModFadeIn | ModScoreV2, "4KFIV2", 1, 1, 1},
+	} {
+		if got := test.Mods.String(); got != test.String {
+			t.Errorf("Expected %q, got %q", test.String, got)
+		}
+		if got := test.Mods.ScoreMultiplier(); math.Abs(got-test.Score) > 1e-9 {
+			t.Errorf("%v: expected a score multiplier of %v, got %v", test.Mods, test.Score, got)
+		}
+		if got := test.Mods.ScoreV2Multiplier(); math.Abs(got-test.ScoreV2) > 1e-9 {
+			t.Errorf("%v: expected a ScoreV2 multiplier of %v, got %v", test.Mods, test.ScoreV2, got)
+		}
+		if got := test.Mods.Rate(); got != test.Rate {
+			t.Errorf("%v: expected a rate of %v, got %v", test.Mods, test.Rate, got)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	// 4 objects over 3 seconds: (HP5 + OD5 + CS4 + 4/3*8) / 38 * 5 rounds to 3
+	b, err := ParseString(testBeatmapHeader + `100,100,1000,1,0
+200,100,2000,1,0
+300,100,3000,1,0
+100,200,4000,2,0,L|300:200,1,200
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.DifficultyMultiplier(); got != 3 {
+		t.Errorf("Expected a difficulty multiplier of 3, got %d", got)
+	}
+	if b.MaxCombo != 6 {
+		t.Errorf("Expected a max combo of 6, got %d", b.MaxCombo)
+	}
+	// Each 300 gets a bonus of (combo - 1) * 12 * multiplier: circles get 0, 0 and 12 * 3,
+	// and the slider end gets 5 * 12 * 3 after its head, tick and tail (70).
+	for _, test := range []struct {
+		Mods  Mods
+		Score int
+	}{
+		{0, 300 + 300 + 336 + 70 + 480},
+		{ModHardRock, 300 + 300 + (300 + 38) + 70 + (300 + 190)},
+		{ModHidden | ModDoubleTime, 300 + 300 + (300 + 42) + 70 + (300 + 213)},
+		{ModRelax, 300*4 + 70},
+	} {
+		if got := b.MaxScore(test.Mods); got != test.Score {
+			t.Errorf("%v: expected a max score of %d, got %d", test.Mods, test.Score, got)
+		}
+	}
+	for _, test := range []struct {
+		Mods       Mods
+		Judgements Judgements
+		Score      int
+	}{
+		{0, Judgements{Count300: 4, MaxCombo: 6}, 1000000},
+		{ModHardRock, Judgements{Count300: 4, MaxCombo: 6}, 1100000},
+		{ModHidden | ModDoubleTime, Judgements{Count300: 4, MaxCombo: 6}, 1272000},
+		{0, Judgements{Count300: 3, Count100: 1, MaxCombo: 6}, 748452},
+		// Half of the objects judged, with a combo of 2 out of 6
+		{0, Judgements{Count300: 2, MaxCombo: 2}, 306017},
+	} {
+		if got := b.ScoreV2(test.Mods, test.Judgements); got != test.Score {
+			t.Errorf("%v %+v: expected a ScoreV2 of %d, got %d", test.Mods, test.Judgements, test.Score, got)
+		}
+	}
+}
+
+func TestStacking(t *testing.T) {
+	// Objects are stacked when less than 600ms (AR9) * 0.7 apart
+	objects := `100,100,1000,1,0
+100,100,1100,1,0
+100,100,1200,1,0
+200,200,2000,2,0,L|300:200,1,100
+300,200,2600,1,0
+300,200,2700,1,0
+400,300,4000,1,0
+400,300,4500,1,0
+`
+	want := []int{2, 1, 0, 0, -1, -2, 0, 0}
+	for _, version := range []string{"v14", "v5"} {
+		b, err := ParseString(strings.Replace(testBeatmapHeader, "v14", version, 1) + objects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]int, len(b.HitObjects))
+		for i, h := range b.HitObjects {
+			got[i] = h.StackHeight
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected stack heights %v, got %v", version, want, got)
+		}
+		offset := 2 * b.StackOffset()
+		if got, want := b.HitObjects[0].StackedPosition, (Point{100 + offset, 100 + offset}); !closeTo(got, want) {
+			t.Errorf("%s: expected the bottom of the stack at %v, got %v", version, want, got)
+		}
+	}
+}
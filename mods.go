@@ -0,0 +1,146 @@
+package parser
+
+import "strings"
+
+// Mods is the bitflag set of game modifiers enabled for a play.
+type Mods int
+
+// The game modifiers, as stored in replays and scores.
+const (
+	ModNoFail Mods = 1 << iota
+	ModEasy
+	ModTouchDevice
+	ModHidden
+	ModHardRock
+	ModSuddenDeath
+	ModDoubleTime
+	ModRelax
+	ModHalfTime
+	ModNightcore // Always set along with DoubleTime
+	ModFlashlight
+	ModAutoplay
+	ModSpunOut
+	ModAutopilot
+	ModPerfect // Always set along with SuddenDeath
+	ModKey4
+	ModKey5
+	ModKey6
+	ModKey7
+	ModKey8
+	ModFadeIn
+	ModRandom
+	ModCinema
+	ModTarget
+	ModKey9
+	ModKeyCoop
+	ModKey1
+	ModKey3
+	ModKey2
+	ModScoreV2
+	ModMirror
+)
+
+var modNames = []string{
+	"NF", "EZ", "TD", "HD", "HR", "SD", "DT", "RX", "HT", "NC",
+	"FL", "AT", "SO", "AP", "PF", "4K", "5K", "6K", "7K", "8K",
+	"FI", "RD", "CN", "TP", "9K", "CO", "1K", "3K", "2K", "V2", "MR",
+}
+
+// Has returns whether all of the given mods are enabled.
+func (m Mods) Has(other Mods) bool { return m&other == other }
+
+// String returns the mods' acronyms, e.g. "HDHR", or "NM" if no mods are enabled.
+// Implied mods (DoubleTime with Nightcore, SuddenDeath with Perfect) are omitted.
+func (m Mods) String() string {
+	if m.Has(ModNightcore) {
+		m &^= ModDoubleTime
+	}
+	if m.Has(ModPerfect) {
+		m &^= ModSuddenDeath
+	}
+	var s strings.Builder
+	for i, name := range modNames {
+		if m&(1<<uint(i)) != 0 {
+			s.WriteString(name)
+		}
+	}
+	if s.Len() == 0 {
+		return "NM"
+	}
+	return s.String()
+}
+
+// Rate returns the speed at which the beatmap is played with the mods:
+// 1.5 with DoubleTime or Nightcore, 0.75 with HalfTime and 1 otherwise.
+func (m Mods) Rate() float64 {
+	switch {
+	case m.Has(ModDoubleTime) || m.Has(ModNightcore):
+		return 1.5
+	case m.Has(ModHalfTime):
+		return 0.75
+	}
+	return 1
+}
+
+// ScoreMultiplier returns the osu!standard ScoreV1 multiplier of the mods.
+func (m Mods) ScoreMultiplier() float64 {
+	mul := 1.0
+	if m.Has(ModNoFail) {
+		mul *= 0.5
+	}
+	if m.Has(ModEasy) {
+		mul *= 0.5
+	}
+	if m.Has(ModHalfTime) {
+		mul *= 0.3
+	}
+	if m.Has(ModHidden) {
+		mul *= 1.06
+	}
+	if m.Has(ModHardRock) {
+		mul *= 1.06
+	}
+	if m.Has(ModDoubleTime) || m.Has(ModNightcore) {
+		mul *= 1.12
+	}
+	if m.Has(ModFlashlight) {
+		mul *= 1.12
+	}
+	if m.Has(ModSpunOut) {
+		mul *= 0.9
+	}
+	if m.Has(ModRelax) || m.Has(ModAutopilot) {
+		mul = 0
+	}
+	return mul
+}
+
+// ScoreV2Multiplier returns the osu!standard ScoreV2 multiplier of the mods.
+func (m Mods) ScoreV2Multiplier() float64 {
+	mul := 1.0
+	if m.Has(ModEasy) {
+		mul *= 0.5
+	}
+	if m.Has(ModHalfTime) {
+		mul *= 0.3
+	}
+	if m.Has(ModHidden) {
+		mul *= 1.06
+	}
+	if m.Has(ModHardRock) {
+		mul *= 1.1
+	}
+	if m.Has(ModDoubleTime) || m.Has(ModNightcore) {
+		mul *= 1.2
+	}
+	if m.Has(ModFlashlight) {
+		mul *= 1.12
+	}
+	if m.Has(ModSpunOut) {
+		mul *= 0.9
+	}
+	if m.Has(ModRelax) || m.Has(ModAutopilot) {
+		mul = 0
+	}
+	return mul
+}
@@ -0,0 +1,152 @@
+package osudb
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+)
+
+// CollectionDB is the content of a collection.db file.
+type CollectionDB struct {
+	Version     int
+	Collections []Collection
+}
+
+// Collection is a named list of beatmaps, identified by their MD5.
+type Collection struct {
+	Name     string
+	Beatmaps []string
+}
+
+// ParseCollectionDBFile parses a collection.db file given a filepath.
+func ParseCollectionDBFile(file string) (db CollectionDB, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ParseCollectionDB(f)
+}
+
+// ParseCollectionDB parses a collection.db file from a reader.
+func ParseCollectionDB(reader io.Reader) (db CollectionDB, err error) {
+	r := osubin.NewReader(reader)
+	var i, count int32
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	db.Version = int(i)
+	if count, err = r.Int(); err != nil {
+		return
+	}
+	db.Collections = make([]Collection, 0)
+	for j := int32(0); j < count; j++ {
+		c := Collection{Beatmaps: make([]string, 0)}
+		if c.Name, err = r.String(); err != nil {
+			return
+		}
+		if i, err = r.Int(); err != nil {
+			return
+		}
+		for k := int32(0); k < i; k++ {
+			var md5 string
+			if md5, err = r.String(); err != nil {
+				return
+			}
+			c.Beatmaps = append(c.Beatmaps, md5)
+		}
+		db.Collections = append(db.Collections, c)
+	}
+	return
+}
+
+// Write encodes the collections in the collection.db format.
+func (db CollectionDB) Write(writer io.Writer) error {
+	w := osubin.NewWriter(writer)
+	w.Int(int32(db.Version))
+	w.Int(int32(len(db.Collections)))
+	for _, c := range db.Collections {
+		w.String(c.Name)
+		w.Int(int32(len(c.Beatmaps)))
+		for _, md5 := range c.Beatmaps {
+			w.String(md5)
+		}
+	}
+	return w.Err()
+}
+
+// WriteFile writes the collections as a collection.db file.
+// The file is written in full before replacing any existing file.
+func (db CollectionDB) WriteFile(file string) error {
+	var buf bytes.Buffer
+	if err := db.Write(&buf); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Collection returns the collection with the given name, if any.
+func (db CollectionDB) Collection(name string) (*Collection, bool) {
+	for i := range db.Collections {
+		if db.Collections[i].Name == name {
+			return &db.Collections[i], true
+		}
+	}
+	return nil, false
+}
+
+// CollectionsOf returns the names of the collections containing the beatmap.
+func (db CollectionDB) CollectionsOf(b parser.Beatmap) []string {
+	names := make([]string, 0)
+	for _, c := range db.Collections {
+		if c.Has(b.MD5) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Has returns whether the collection contains the beatmap with the given MD5.
+func (c Collection) Has(md5 string) bool {
+	for _, m := range c.Beatmaps {
+		if strings.EqualFold(m, md5) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds beatmaps to the collection, skipping the ones it already contains.
+func (c *Collection) Add(md5s ...string) {
+	for _, md5 := range md5s {
+		if md5 != "" && !c.Has(md5) {
+			c.Beatmaps = append(c.Beatmaps, md5)
+		}
+	}
+}
+
+// Merge adds the collections of another database.
+// Collections with the same name are merged, without duplicating beatmaps,
+// and the order of existing collections and beatmaps is kept.
+func (db *CollectionDB) Merge(other CollectionDB) {
+	if other.Version > db.Version {
+		db.Version = other.Version
+	}
+	for _, oc := range other.Collections {
+		c, ok := db.Collection(oc.Name)
+		if !ok {
+			db.Collections = append(db.Collections, Collection{Name: oc.Name, Beatmaps: make([]string, 0)})
+			c = &db.Collections[len(db.Collections)-1]
+		}
+		c.Add(oc.Beatmaps...)
+	}
+}
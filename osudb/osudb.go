@@ -0,0 +1,427 @@
+// Package osudb provides parsers for the game's database files:
+// osu!.db (the beatmap library), collection.db and scores.db.
+// Entries are keyed by beatmap MD5, matching the MD5 field of parsed beatmaps.
+package osudb
+
+import (
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+)
+
+// Versions of the database format.
+const (
+	// Difficulty values are stored as floats and star ratings are cached.
+	floatDifficultyVersion = 20140609
+	// Beatmap entries are no longer prefixed by their size.
+	noEntrySizeVersion = 20191106
+)
+
+// RankedStatus is the online status of a beatmap.
+type RankedStatus int
+
+// The possible ranked statuses.
+const (
+	StatusUnknown RankedStatus = iota
+	StatusUnsubmitted
+	StatusPending // Also WIP and graveyard
+	StatusUnused
+	StatusRanked
+	StatusApproved
+	StatusQualified
+	StatusLoved
+)
+
+// OsuDB is the content of an osu!.db file.
+type OsuDB struct {
+	Version         int
+	FolderCount     int
+	AccountUnlocked bool
+	UnlockDate      time.Time
+	PlayerName      string
+	Beatmaps        []BeatmapEntry
+	Permissions     int
+}
+
+// TimingPoint is a timing point, as cached in osu!.db.
+type TimingPoint struct {
+	BeatLength  float64
+	Offset      float64
+	Uninherited bool
+}
+
+// BeatmapEntry is a beatmap of the library.
+type BeatmapEntry struct {
+	Artist            string
+	ArtistUnicode     string
+	Title             string
+	TitleUnicode      string
+	Creator           string
+	Version           string // Difficulty name
+	AudioFilename     string
+	MD5               string
+	Filename          string // .osu file name
+	Status            RankedStatus
+	NbCircles         int
+	NbSliders         int
+	NbSpinners        int
+	LastModified      time.Time
+	ApproachRate      float64
+	CircleSize        float64
+	HPDrainRate       float64
+	OverallDifficulty float64
+	SliderMultiplier  float64
+	StarRatings       [4]map[parser.Mods]float64 // Per mode, per mod combination
+	DrainingTime      int                        // in seconds
+	TotalTime         int                        // in ms
+	PreviewTime       int                        // in ms
+	TimingPoints      []TimingPoint
+	BeatmapID         int
+	BeatmapSetID      int
+	ThreadID          int
+	Grades            [4]int // Per mode
+	LocalOffset       int
+	StackLeniency     float64
+	Mode              int
+	Source            string
+	Tags              string
+	OnlineOffset      int
+	TitleFont         string
+	Unplayed          bool
+	LastPlayed        time.Time
+	IsOsz2            bool
+	FolderName        string
+	LastChecked       time.Time
+	IgnoreSounds      bool
+	IgnoreSkin        bool
+	DisableStoryboard bool
+	DisableVideo      bool
+	VisualOverride    bool
+	LastModification  int
+	ManiaScrollSpeed  int
+}
+
+// ParseOsuDBFile parses an osu!.db file given a filepath.
+func ParseOsuDBFile(file string) (db OsuDB, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ParseOsuDB(f)
+}
+
+// ParseOsuDB parses an osu!.db file from a reader.
+func ParseOsuDB(reader io.Reader) (db OsuDB, err error) {
+	r := osubin.NewReader(reader)
+	var (
+		i     int32
+		count int32
+	)
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	db.Version = int(i)
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	db.FolderCount = int(i)
+	if db.AccountUnlocked, err = r.Bool(); err != nil {
+		return
+	}
+	if db.UnlockDate, err = r.Time(); err != nil {
+		return
+	}
+	if db.PlayerName, err = r.String(); err != nil {
+		return
+	}
+	if count, err = r.Int(); err != nil {
+		return
+	}
+	db.Beatmaps = make([]BeatmapEntry, 0)
+	for j := int32(0); j < count; j++ {
+		var e BeatmapEntry
+		if e, err = parseBeatmapEntry(r, db.Version); err != nil {
+			return
+		}
+		db.Beatmaps = append(db.Beatmaps, e)
+	}
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	db.Permissions = int(i)
+	return
+}
+
+// Reads a difficulty value, stored as a byte in old versions.
+func readDifficulty(r *osubin.Reader, version int) (float64, error) {
+	if version < floatDifficultyVersion {
+		b, err := r.Byte()
+		return float64(b), err
+	}
+	f, err := r.Single()
+	return float64(f), err
+}
+
+// Reads the star ratings of a mode, as (0x08, mods, 0x0d, stars) entries.
+// Recent versions store the stars as a float, with 0x0c instead of 0x0d.
+func readStarRatings(r *osubin.Reader) (m map[parser.Mods]float64, err error) {
+	var (
+		count int32
+		mods  int32
+		tag   byte
+	)
+	if count, err = r.Int(); err != nil {
+		return
+	}
+	m = make(map[parser.Mods]float64)
+	for j := int32(0); j < count; j++ {
+		if _, err = r.Byte(); err != nil {
+			return
+		}
+		if mods, err = r.Int(); err != nil {
+			return
+		}
+		if tag, err = r.Byte(); err != nil {
+			return
+		}
+		var stars float64
+		switch tag {
+		case 0x0c:
+			var f float32
+			if f, err = r.Single(); err != nil {
+				return
+			}
+			stars = float64(f)
+		case 0x0d:
+			if stars, err = r.Double(); err != nil {
+				return
+			}
+		default:
+			return nil, parser.ParseError("Invalid star rating type")
+		}
+		m[parser.Mods(mods)] = stars
+	}
+	return
+}
+
+func parseBeatmapEntry(r *osubin.Reader, version int) (e BeatmapEntry, err error) {
+	var (
+		b byte
+		s int16
+		i int32
+		f float32
+	)
+	if version < noEntrySizeVersion {
+		if _, err = r.Int(); err != nil {
+			return
+		}
+	}
+	for _, str := range []*string{
+		&e.Artist, &e.ArtistUnicode, &e.Title, &e.TitleUnicode, &e.Creator,
+		&e.Version, &e.AudioFilename, &e.MD5, &e.Filename,
+	} {
+		if *str, err = r.String(); err != nil {
+			return
+		}
+	}
+	if b, err = r.Byte(); err != nil {
+		return
+	}
+	e.Status = RankedStatus(b)
+	for _, n := range []*int{&e.NbCircles, &e.NbSliders, &e.NbSpinners} {
+		if s, err = r.Short(); err != nil {
+			return
+		}
+		*n = int(uint16(s))
+	}
+	if e.LastModified, err = r.Time(); err != nil {
+		return
+	}
+	for _, d := range []*float64{&e.ApproachRate, &e.CircleSize, &e.HPDrainRate, &e.OverallDifficulty} {
+		if *d, err = readDifficulty(r, version); err != nil {
+			return
+		}
+	}
+	if e.SliderMultiplier, err = r.Double(); err != nil {
+		return
+	}
+	if version >= floatDifficultyVersion {
+		for mode := range e.StarRatings {
+			if e.StarRatings[mode], err = readStarRatings(r); err != nil {
+				return
+			}
+		}
+	}
+	for _, n := range []*int{&e.DrainingTime, &e.TotalTime, &e.PreviewTime} {
+		if i, err = r.Int(); err != nil {
+			return
+		}
+		*n = int(i)
+	}
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	e.TimingPoints = make([]TimingPoint, 0)
+	for j := int32(0); j < i; j++ {
+		tp := TimingPoint{}
+		if tp.BeatLength, err = r.Double(); err != nil {
+			return
+		}
+		if tp.Offset, err = r.Double(); err != nil {
+			return
+		}
+		if tp.Uninherited, err = r.Bool(); err != nil {
+			return
+		}
+		e.TimingPoints = append(e.TimingPoints, tp)
+	}
+	for _, n := range []*int{&e.BeatmapID, &e.BeatmapSetID, &e.ThreadID} {
+		if i, err = r.Int(); err != nil {
+			return
+		}
+		*n = int(i)
+	}
+	for mode := range e.Grades {
+		if b, err = r.Byte(); err != nil {
+			return
+		}
+		e.Grades[mode] = int(b)
+	}
+	if s, err = r.Short(); err != nil {
+		return
+	}
+	e.LocalOffset = int(s)
+	if f, err = r.Single(); err != nil {
+		return
+	}
+	e.StackLeniency = float64(f)
+	if b, err = r.Byte(); err != nil {
+		return
+	}
+	e.Mode = int(b)
+	if e.Source, err = r.String(); err != nil {
+		return
+	}
+	if e.Tags, err = r.String(); err != nil {
+		return
+	}
+	if s, err = r.Short(); err != nil {
+		return
+	}
+	e.OnlineOffset = int(s)
+	if e.TitleFont, err = r.String(); err != nil {
+		return
+	}
+	if e.Unplayed, err = r.Bool(); err != nil {
+		return
+	}
+	if e.LastPlayed, err = r.Time(); err != nil {
+		return
+	}
+	if e.IsOsz2, err = r.Bool(); err != nil {
+		return
+	}
+	if e.FolderName, err = r.String(); err != nil {
+		return
+	}
+	if e.LastChecked, err = r.Time(); err != nil {
+		return
+	}
+	for _, flag := range []*bool{&e.IgnoreSounds, &e.IgnoreSkin, &e.DisableStoryboard, &e.DisableVideo, &e.VisualOverride} {
+		if *flag, err = r.Bool(); err != nil {
+			return
+		}
+	}
+	if version < floatDifficultyVersion {
+		if _, err = r.Short(); err != nil {
+			return
+		}
+	}
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	e.LastModification = int(i)
+	if b, err = r.Byte(); err != nil {
+		return
+	}
+	e.ManiaScrollSpeed = int(b)
+	return
+}
+
+// Beatmap returns the entry's metadata as a Beatmap.
+// Hit objects, break times and other information not stored in the database are left empty.
+func (e BeatmapEntry) Beatmap() parser.Beatmap {
+	b := parser.Beatmap{
+		Artist:            e.Artist,
+		ArtistUnicode:     e.ArtistUnicode,
+		Title:             e.Title,
+		TitleUnicode:      e.TitleUnicode,
+		AudioFilename:     e.AudioFilename,
+		Creator:           e.Creator,
+		Source:            e.Source,
+		Tags:              strings.Fields(e.Tags),
+		MD5:               e.MD5,
+		Version:           e.Version,
+		BeatmapID:         e.BeatmapID,
+		BeatmapSetID:      e.BeatmapSetID,
+		Mode:              e.Mode,
+		StackLeniency:     e.StackLeniency,
+		PreviewTime:       e.PreviewTime,
+		CircleSize:        e.CircleSize,
+		HPDrainRate:       e.HPDrainRate,
+		OverallDifficulty: e.OverallDifficulty,
+		ApproachRate:      e.ApproachRate,
+		HasApproachRate:   true,
+		NbCircles:         e.NbCircles,
+		NbSliders:         e.NbSliders,
+		NbSpinners:        e.NbSpinners,
+		TotalTime:         e.TotalTime / 1000,
+		DrainingTime:      e.DrainingTime,
+		SliderMultiplier:  e.SliderMultiplier,
+		TimingPoints:      make([]parser.TimingPoint, 0, len(e.TimingPoints)),
+		HitObjects:        make([]parser.HitObject, 0),
+		BreakTimes:        make([]parser.BreakTime, 0),
+		BackgroundColours: make([]parser.BackgroundColour, 0),
+		Colours:           parser.Colours{ComboColours: make([]color.RGBA, 0)},
+		Storyboard:        parser.Storyboard{Sprites: make([]parser.Sprite, 0), Samples: make([]parser.Sample, 0)},
+		OtherAttributes:   make(map[string]string),
+		Editor:            parser.Editor{Bookmarks: make([]int, 0)},
+	}
+	var last parser.TimingPoint
+	for _, tp := range e.TimingPoints {
+		p := parser.TimingPoint{Offset: tp.Offset, Velocity: 1, TimingChange: tp.Uninherited, SampleVolume: 100}
+		if tp.Uninherited && tp.BeatLength > 0 {
+			p.BeatLength = tp.BeatLength
+			p.Bpm = math.Trunc(60000.0/tp.BeatLength + 0.5)
+			if b.BpmMin == 0 || p.Bpm < b.BpmMin {
+				b.BpmMin = p.Bpm
+			}
+			b.BpmMax = math.Max(b.BpmMax, p.Bpm)
+		} else {
+			p.BeatLength, p.Bpm = last.BeatLength, last.Bpm
+			if tp.BeatLength < 0 {
+				p.Velocity = math.Abs(100 / tp.BeatLength)
+			}
+		}
+		b.TimingPoints = append(b.TimingPoints, p)
+		last = p
+	}
+	return b
+}
+
+// Find returns the beatmap entry with the given MD5, if any.
+func (db OsuDB) Find(md5 string) (BeatmapEntry, bool) {
+	for _, e := range db.Beatmaps {
+		if strings.EqualFold(e.MD5, md5) {
+			return e, true
+		}
+	}
+	return BeatmapEntry{}, false
+}
@@ -0,0 +1,171 @@
+package osudb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+)
+
+func TestParseOsuDB(t *testing.T) {
+	var buf bytes.Buffer
+	w := osubin.NewWriter(&buf)
+	w.Int(20191106)
+	w.Int(1)
+	w.Bool(true)
+	w.Time(time.Time{})
+	w.String("peppy")
+	w.Int(1)
+	// Beatmap entry
+	for _, s := range []string{"keeno", "keeno", "Crack", "Crack", "Azure", "Insane", "Crack.mp3",
+		"3c100eba27a3d0560ec2d35d7c1d66bf", "keeno - Crack (Azure) [Insane].osu"} {
+		w.String(s)
+	}
+	w.Byte(byte(StatusRanked))
+	w.Short(199)
+	w.Short(77)
+	w.Short(3)
+	w.Time(time.Date(2013, 1, 2, 3, 4, 5, 0, time.UTC))
+	for _, d := range []float32{6, 4, 6, 6} {
+		w.Single(d)
+	}
+	w.Double(1.8)
+	for mode := 0; mode < 4; mode++ {
+		if mode == 0 {
+			w.Int(2)
+			w.Byte(0x08)
+			w.Int(0)
+			w.Byte(0x0d)
+			w.Double(4.5)
+			w.Byte(0x08)
+			w.Int(int32(parser.ModDoubleTime))
+			w.Byte(0x0c)
+			w.Single(6.25)
+		} else {
+			w.Int(0)
+		}
+	}
+	w.Int(126)
+	w.Int(127000)
+	w.Int(85113)
+	w.Int(2)
+	w.Double(645.161290322581)
+	w.Double(-1660)
+	w.Bool(true)
+	w.Double(-50)
+	w.Double(19952)
+	w.Bool(false)
+	w.Int(170428)
+	w.Int(56348)
+	w.Int(0)
+	w.Bytes([]byte{0, 9, 9, 9})
+	w.Short(0)
+	w.Single(0.6)
+	w.Byte(0)
+	w.String("Vocaloid")
+	w.String("append dark")
+	w.Short(0)
+	w.String("")
+	w.Bool(false)
+	w.Time(time.Time{})
+	w.Bool(false)
+	w.String("56348 keeno - Crack")
+	w.Time(time.Time{})
+	w.Bytes([]byte{0, 0, 0, 0, 0})
+	w.Int(0)
+	w.Byte(0)
+	// Permissions
+	w.Int(1)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+
+	db, err := ParseOsuDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.PlayerName != "peppy" || len(db.Beatmaps) != 1 || db.Permissions != 1 {
+		t.Fatalf("Unexpected database %+v", db)
+	}
+	e, ok := db.Find("3C100EBA27A3D0560EC2D35D7C1D66BF")
+	if !ok {
+		t.Fatal("Expected to find the beatmap by MD5")
+	}
+	if e.Title != "Crack" || e.BeatmapID != 170428 || e.FolderName != "56348 keeno - Crack" || e.Grades[0] != 0 {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e.StarRatings[0][parser.ModDoubleTime] != 6.25 || e.StarRatings[0][0] != 4.5 {
+		t.Errorf("Unexpected star ratings %v", e.StarRatings[0])
+	}
+	b := e.Beatmap()
+	if b.MD5 != e.MD5 || b.TotalTime != 127 || b.BpmMax != 93 || b.TimingPoints[1].Velocity != 2 || b.Tags[1] != "dark" {
+		t.Errorf("Unexpected beatmap %+v", b)
+	}
+}
+
+func TestCollectionDB(t *testing.T) {
+	db := CollectionDB{Version: 20150203, Collections: []Collection{
+		{Name: "Farm", Beatmaps: []string{"aaaa", "bbbb"}},
+	}}
+	other := CollectionDB{Version: 20160101, Collections: []Collection{
+		{Name: "Farm", Beatmaps: []string{"BBBB", "cccc"}},
+		{Name: "Tech", Beatmaps: []string{"dddd"}},
+	}}
+	db.Merge(other)
+	var buf bytes.Buffer
+	if err := db.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseCollectionDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CollectionDB{Version: 20160101, Collections: []Collection{
+		{Name: "Farm", Beatmaps: []string{"aaaa", "bbbb", "cccc"}},
+		{Name: "Tech", Beatmaps: []string{"dddd"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if names := got.CollectionsOf(parser.Beatmap{MD5: "DDDD"}); len(names) != 1 || names[0] != "Tech" {
+		t.Errorf("Unexpected collections %v", names)
+	}
+}
+
+func TestScoresDB(t *testing.T) {
+	var buf bytes.Buffer
+	w := osubin.NewWriter(&buf)
+	w.Int(20191106)
+	w.Int(1)
+	w.String("3c100eba27a3d0560ec2d35d7c1d66bf")
+	w.Int(2)
+	for _, score := range []int32{1000000, 500000} {
+		w.Byte(0)
+		w.Int(20191106)
+		w.String("3c100eba27a3d0560ec2d35d7c1d66bf")
+		w.String("peppy")
+		w.String("")
+		for _, count := range []int16{300, 2, 0, 50, 1, 0} {
+			w.Short(count)
+		}
+		w.Int(score)
+		w.Short(429)
+		w.Bool(true)
+		w.Int(int32(parser.ModHidden))
+		w.String("")
+		w.Time(time.Date(2019, 11, 6, 0, 0, 0, 0, time.UTC))
+		w.Int(-1)
+		w.Long(123456789)
+	}
+	db, err := ParseScoresDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := db.ScoresOf(parser.Beatmap{MD5: "3c100eba27a3d0560ec2d35d7c1d66bf"})
+	if len(scores) != 2 || scores[1].Score != 500000 || scores[0].Mods != parser.ModHidden || scores[0].OnlineScoreID != 123456789 {
+		t.Errorf("Unexpected scores %+v", scores)
+	}
+}
@@ -0,0 +1,77 @@
+package osudb
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+	"github.com/natsukagami/go-osu-parser/replay"
+)
+
+// ScoresDB is the content of a scores.db file.
+type ScoresDB struct {
+	Version  int
+	Beatmaps []BeatmapScores
+}
+
+// BeatmapScores are the local scores set on a beatmap.
+// The scores are replays without frames.
+type BeatmapScores struct {
+	MD5    string
+	Scores []replay.Replay
+}
+
+// ParseScoresDBFile parses a scores.db file given a filepath.
+func ParseScoresDBFile(file string) (db ScoresDB, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ParseScoresDB(f)
+}
+
+// ParseScoresDB parses a scores.db file from a reader.
+func ParseScoresDB(reader io.Reader) (db ScoresDB, err error) {
+	r := osubin.NewReader(reader)
+	var i, count int32
+	if i, err = r.Int(); err != nil {
+		return
+	}
+	db.Version = int(i)
+	if count, err = r.Int(); err != nil {
+		return
+	}
+	db.Beatmaps = make([]BeatmapScores, 0)
+	for j := int32(0); j < count; j++ {
+		bs := BeatmapScores{Scores: make([]replay.Replay, 0)}
+		if bs.MD5, err = r.String(); err != nil {
+			return
+		}
+		if i, err = r.Int(); err != nil {
+			return
+		}
+		for k := int32(0); k < i; k++ {
+			var s replay.Replay
+			// Scores share the replay format, with no replay data
+			if s, err = replay.Parse(r); err != nil {
+				return
+			}
+			bs.Scores = append(bs.Scores, s)
+		}
+		db.Beatmaps = append(db.Beatmaps, bs)
+	}
+	return
+}
+
+// ScoresOf returns the local scores set on the beatmap.
+func (db ScoresDB) ScoresOf(b parser.Beatmap) []replay.Replay {
+	for _, bs := range db.Beatmaps {
+		if strings.EqualFold(bs.MD5, b.MD5) {
+			return bs.Scores
+		}
+	}
+	return make([]replay.Replay, 0)
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// MissingFilesError lists the files referenced by beatmaps but missing from a set.
+type MissingFilesError []string
+
+func (m MissingFilesError) Error() string {
+	return "Missing referenced files: " + strings.Join(m, ", ")
+}
+
+// OszBuilder collects the encoded beatmaps and assets of a beatmap set,
+// to be written as an .osz archive.
+type OszBuilder struct {
+	files []oszBuilderFile
+}
+
+type oszBuilderFile struct {
+	Name    string
+	Data    []byte
+	Beatmap *Beatmap // The parsed beatmap, for .osu files
+}
+
+// NewOszBuilder creates an empty OszBuilder.
+func NewOszBuilder() *OszBuilder {
+	return &OszBuilder{files: make([]oszBuilderFile, 0)}
+}
+
+// AddBeatmap adds an encoded .osu file to the set.
+// The file is parsed to find the files it references.
+// A file with the same name, such as an older version of the difficulty, is replaced.
+func (o *OszBuilder) AddBeatmap(name string, data []byte) error {
+	b, err := ParseBytes(data)
+	if err != nil {
+		return err
+	}
+	o.add(name, data, &b)
+	return nil
+}
+
+// AddAsset adds any other file to the set, e.g. the audio or the background.
+func (o *OszBuilder) AddAsset(name string, data []byte) {
+	o.add(name, data, nil)
+}
+
+// AddAssetFile adds a file from the disk to the set, under the given name.
+func (o *OszBuilder) AddAssetFile(name, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	o.add(name, data, nil)
+	return nil
+}
+
+// Adds a file, replacing any file with the same name.
+func (o *OszBuilder) add(name string, data []byte, b *Beatmap) {
+	name = normalizePath(name)
+	for i, f := range o.files {
+		if strings.EqualFold(f.Name, name) {
+			o.files[i].Data, o.files[i].Beatmap = data, b
+			return
+		}
+	}
+	o.files = append(o.files, oszBuilderFile{name, data, b})
+}
+
+func (o *OszBuilder) has(name string) bool {
+	name = normalizePath(name)
+	for _, f := range o.files {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every file referenced by the beatmaps is in the set:
+// audio, background, video and custom hitsound files.
+func (o *OszBuilder) Validate() error {
+	missing := make(MissingFilesError, 0)
+	seen := make(map[string]bool)
+	check := func(name string) {
+		if name == "" || seen[strings.ToLower(name)] {
+			return
+		}
+		seen[strings.ToLower(name)] = true
+		if !o.has(name) {
+			missing = append(missing, name)
+		}
+	}
+	for _, f := range o.files {
+		b := f.Beatmap
+		if b == nil {
+			continue
+		}
+		check(b.AudioFilename)
+		check(b.BgFilename)
+		check(b.VideoFilename)
+		for _, name := range b.hitsoundFilenames() {
+			check(name)
+		}
+		for _, name := range b.Storyboard.Filenames() {
+			check(name)
+		}
+	}
+	if len(missing) > 0 {
+		return missing
+	}
+	return nil
+}
+
+// Write validates the set and writes it as an .osz archive.
+func (o *OszBuilder) Write(w io.Writer) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range o.files {
+		fw, err := zw.Create(f.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteFile validates the set and writes it as an .osz file.
+func (o *OszBuilder) WriteFile(file string) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := o.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
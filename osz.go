@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Skinnable hitsound filenames, which beatmaps may override.
+var hitsoundReg = regexp.MustCompile(`^(normal|soft|drum)-(hit(normal|whistle|finish|clap)|slider(slide|whistle|tick))[0-9]*\.(wav|ogg|mp3)$`)
+
+// Osz is a beatmap set archive (.osz), holding every difficulty
+// of the set along with its assets.
+type Osz struct {
+	Files    []OszFile
+	Beatmaps []OszBeatmap
+	closer   io.Closer
+}
+
+// OszFile is a file inside an .osz archive.
+type OszFile struct {
+	Name string // Path inside the archive, with forward slashes
+	Size int64
+	file *zip.File
+}
+
+// OszBeatmap is a parsed difficulty inside an .osz archive.
+type OszBeatmap struct {
+	Filename string
+	Beatmap
+}
+
+// BeatmapAssets are the files referenced by a beatmap.
+// Missing files are nil.
+type BeatmapAssets struct {
+	Audio      *OszFile
+	Background *OszFile
+	Video      *OszFile
+	Hitsounds  []OszFile
+	Storyboard []OszFile // Images and samples of the beatmap's own storyboard
+}
+
+// Open opens the file for reading.
+func (f OszFile) Open() (io.ReadCloser, error) {
+	return f.file.Open()
+}
+
+// Bytes reads the whole file.
+func (f OszFile) Bytes() ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// OpenOsz opens and parses an .osz file given a filepath.
+// The archive must be closed after use.
+func OpenOsz(file string) (*Osz, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		f.Close()
+		return nil, ParseError("Invalid file")
+	}
+	o, err := ReadOsz(f, stat.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	o.closer = f
+	return o, nil
+}
+
+// ReadOsz parses an .osz archive of the given size, parsing every .osu file inside.
+func ReadOsz(r io.ReaderAt, size int64) (*Osz, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	o := &Osz{Files: make([]OszFile, 0), Beatmaps: make([]OszBeatmap, 0)}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		file := OszFile{Name: normalizePath(f.Name), Size: int64(f.UncompressedSize64), file: f}
+		o.Files = append(o.Files, file)
+		if strings.ToLower(path.Ext(file.Name)) != ".osu" {
+			continue
+		}
+		bytes, err := file.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ParseBytes(bytes)
+		if err != nil {
+			return nil, ParseError(fmt.Sprintf("%s: %v", file.Name, err))
+		}
+		o.Beatmaps = append(o.Beatmaps, OszBeatmap{file.Name, b})
+	}
+	return o, nil
+}
+
+// Close closes the underlying file, if the archive was opened with OpenOsz.
+func (o *Osz) Close() error {
+	if o.closer == nil {
+		return nil
+	}
+	return o.closer.Close()
+}
+
+// Paths in .osu files use backslashes, paths in archives use slashes.
+func normalizePath(name string) string {
+	return strings.TrimPrefix(strings.Replace(name, "\\", "/", -1), "./")
+}
+
+// File finds a file in the archive. Like the game, the lookup is case-insensitive,
+// and accepts paths with backslashes.
+func (o *Osz) File(name string) (OszFile, bool) {
+	name = normalizePath(name)
+	for _, f := range o.Files {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return OszFile{}, false
+}
+
+func (o *Osz) filePtr(name string) *OszFile {
+	if name == "" {
+		return nil
+	}
+	if f, ok := o.File(name); ok {
+		return &f
+	}
+	return nil
+}
+
+// Assets returns the files of the archive referenced by a beatmap:
+// its audio, background, video and hitsounds.
+// Hitsounds include custom hitsound filenames of the hit objects, and
+// any hitsound sample the set overrides.
+func (o *Osz) Assets(b Beatmap) BeatmapAssets {
+	a := BeatmapAssets{
+		Audio:      o.filePtr(b.AudioFilename),
+		Background: o.filePtr(b.BgFilename),
+		Video:      o.filePtr(b.VideoFilename),
+		Hitsounds:  make([]OszFile, 0),
+		Storyboard: make([]OszFile, 0),
+	}
+	seen := make(map[string]bool)
+	add := func(f OszFile) {
+		if !seen[f.Name] {
+			seen[f.Name] = true
+			a.Hitsounds = append(a.Hitsounds, f)
+		}
+	}
+	for _, name := range b.hitsoundFilenames() {
+		if f, ok := o.File(name); ok {
+			add(f)
+		}
+	}
+	for _, f := range o.Files {
+		if !strings.Contains(f.Name, "/") && hitsoundReg.MatchString(strings.ToLower(f.Name)) {
+			add(f)
+		}
+	}
+	sort.Slice(a.Hitsounds, func(i, j int) bool { return a.Hitsounds[i].Name < a.Hitsounds[j].Name })
+	for _, name := range b.Storyboard.Filenames() {
+		if f, ok := o.File(name); ok {
+			a.Storyboard = append(a.Storyboard, f)
+		}
+	}
+	return a
+}
+
+// Gets the custom hitsound filenames used by the hit objects.
+func (b Beatmap) hitsoundFilenames() []string {
+	names := make([]string, 0)
+	add := func(a *Addition) {
+		if a != nil && a.Hitsound != "" {
+			names = append(names, a.Hitsound)
+		}
+	}
+	for _, h := range b.HitObjects {
+		add(h.Additions)
+		for _, e := range h.Edges {
+			add(e.Additions)
+		}
+	}
+	return names
+}
+
+// Storyboard returns the complete storyboard of a beatmap: its own [Events] storyboard
+// merged with the set's .osb file, if the archive has one.
+func (o *Osz) Storyboard(b Beatmap) (Storyboard, error) {
+	for _, f := range o.Files {
+		if strings.Contains(f.Name, "/") || strings.ToLower(path.Ext(f.Name)) != ".osb" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return b.Storyboard, err
+		}
+		defer r.Close()
+		s, err := ParseStoryboard(r)
+		if err != nil {
+			return b.Storyboard, ParseError(fmt.Sprintf("%s: %v", f.Name, err))
+		}
+		return b.Storyboard.Merge(s), nil
+	}
+	return b.Storyboard, nil
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -16,10 +17,13 @@ type beatmapParser struct {
 	TimingLines    []string
 	HitObjectLines []string
 	EventLines     []string
+	VariableLines  []string
 	OsuSection     string
+	SeenKeys       map[string]bool
 }
 
 func (b *beatmapParser) ReadLine(line string) (err error) {
+	raw := strings.TrimRight(line, " \r\n")
 	line = strings.Trim(line, " \r\n")
 	if len(line) == 0 {
 		return
@@ -34,7 +38,10 @@ func (b *beatmapParser) ReadLine(line string) (err error) {
 	case "hitobjects":
 		b.HitObjectLines = append(b.HitObjectLines, line)
 	case "events":
-		b.EventLines = append(b.EventLines, line)
+		// Storyboard commands are nested by indentation
+		b.EventLines = append(b.EventLines, raw)
+	case "variables":
+		b.VariableLines = append(b.VariableLines, line)
 	default:
 		if b.OsuSection == "" {
 			fmtRegex := regexp.MustCompile("^osu file format (v[0-9]+)$")
@@ -89,11 +96,54 @@ func (b *beatmapParser) ReadLine(line string) (err error) {
 					return
 				}
 			case "SampleSet":
-				b.SampleSet = match[2]
+				// Like in the game, anything but Soft or Drum is the normal sample set
+				switch match[2] {
+				case "Soft":
+					b.SampleSet = SampleSetSoft
+				case "Drum":
+					b.SampleSet = SampleSetDrum
+				default:
+					b.SampleSet = SampleSetNormal
+				}
 			case "Countdown":
-				if b.Countdown, err = strconv.Atoi(match[2]); err != nil {
+				var n int
+				if n, err = strconv.Atoi(match[2]); err != nil {
+					return
+				}
+				b.Countdown = Countdown(n)
+			case "CountdownOffset":
+				if b.CountdownOffset, err = strconv.Atoi(match[2]); err != nil {
+					return
+				}
+			case "EpilepsyWarning":
+				b.EpilepsyWarning = (match[2] == "1")
+			case "WidescreenStoryboard":
+				b.WidescreenStoryboard = (match[2] == "1")
+			case "SpecialStyle":
+				b.SpecialStyle = (match[2] == "1")
+			case "UseSkinSprites":
+				b.UseSkinSprites = (match[2] == "1")
+			case "SkinPreference":
+				b.SkinPreference = match[2]
+			case "AlwaysShowPlayfield":
+				b.AlwaysShowPlayfield = (match[2] == "1")
+			case "OverlayPosition":
+				var n int
+				if n, err = parseEnum(match[2], overlayPositionNames); err != nil {
 					return
 				}
+				b.OverlayPosition = OverlayPosition(n)
+			case "SamplesMatchPlaybackRate":
+				b.SamplesMatchPlaybackRate = (match[2] == "1")
+			case "StoryFireInFront":
+				b.StoryFireInFront = (match[2] == "1")
+			case "EditorBookmarks":
+				// Bookmarks of old file formats
+				if !b.SeenKeys["Bookmarks"] {
+					if b.Bookmarks, err = parseBookmarks(match[2]); err != nil {
+						return
+					}
+				}
 			case "BeatDivisor":
 				if b.BeatDivisor, err = strconv.Atoi(match[2]); err != nil {
 					return
@@ -103,7 +153,16 @@ func (b *beatmapParser) ReadLine(line string) (err error) {
 					return
 				}
 			case "DistanceSpacing":
-				if b.DistanceSpacing, err = strconv.Atoi(match[2]); err != nil {
+				if b.DistanceSpacing, err = strconv.ParseFloat(match[2], 64); err != nil {
+					return
+				}
+			case "TimelineZoom":
+				if b.TimelineZoom, err = strconv.ParseFloat(match[2], 64); err != nil {
+					return
+				}
+			case "Bookmarks":
+				// Takes precedence over EditorBookmarks
+				if b.Bookmarks, err = parseBookmarks(match[2]); err != nil {
 					return
 				}
 			case "GridSize":
@@ -135,6 +194,8 @@ func (b *beatmapParser) ReadLine(line string) (err error) {
 			default:
 				b.OtherAttributes[match[1]] = match[2]
 			}
+			// Only keys whose value parsed count as present
+			b.SeenKeys[match[1]] = true
 		}
 	}
 	return
@@ -145,13 +206,26 @@ func (b *beatmapParser) BuildBeatmap() (*Beatmap, error) {
 		b.Tags = strings.Split(tags, " ")
 		delete(b.OtherAttributes, "Tags")
 	}
+	b.applyDefaults()
 	var err error
+	if err = b.parseColours(); err != nil {
+		return nil, err
+	}
+	sb := newStoryboardParser()
+	for _, line := range b.VariableLines {
+		sb.AddVariable(line)
+	}
 	for _, line := range b.EventLines {
-		if err = b.parseEvent(line); err != nil {
+		if err = b.parseEvent(strings.TrimLeft(line, " ")); err != nil {
+			return nil, err
+		}
+		if err = sb.ReadLine(line); err != nil {
 			return nil, err
 		}
 	}
+	b.Storyboard = sb.Build()
 	sortBreakTimes(b.BreakTimes)
+	sort.SliceStable(b.BackgroundColours, func(i, j int) bool { return b.BackgroundColours[i].Time < b.BackgroundColours[j].Time })
 	for _, line := range b.TimingLines {
 		if err = b.parseTimingPoint(line); err != nil {
 			return nil, err
@@ -170,16 +244,86 @@ func (b *beatmapParser) BuildBeatmap() (*Beatmap, error) {
 		}
 	}
 	sortHitObjects(b.HitObjects)
+	b.computeCombos()
 	b.computeMaxCombo()
 	b.computeDuration()
+	b.computeStacking()
 	return b.Beatmap, nil
 }
 
+// Fill in the values the game uses for keys missing from the file.
+// None of these depend on the format version: old formats simply lack
+// some keys (like ApproachRate), and the missing key gets its default.
+func (b *beatmapParser) applyDefaults() {
+	if !b.SeenKeys["SampleSet"] {
+		b.SampleSet = SampleSetNormal
+	}
+	if !b.SeenKeys["Countdown"] {
+		b.Countdown = CountdownNormal
+	}
+	if !b.SeenKeys["StoryFireInFront"] {
+		b.StoryFireInFront = true
+	}
+	if !b.SeenKeys["StackLeniency"] {
+		b.StackLeniency = 0.7
+	}
+	if !b.SeenKeys["SliderTickRate"] {
+		b.SliderTickRate = 1
+	}
+	if !b.SeenKeys["SliderMultiplier"] {
+		b.SliderMultiplier = 1.4
+	}
+	if !b.SeenKeys["HPDrainRate"] {
+		b.HPDrainRate = 5
+	}
+	if !b.SeenKeys["CircleSize"] {
+		b.CircleSize = 5
+	}
+	if !b.SeenKeys["OverallDifficulty"] {
+		b.OverallDifficulty = 5
+	}
+	if !b.SeenKeys["DistanceSpacing"] {
+		b.DistanceSpacing = 1
+	}
+	if !b.SeenKeys["BeatDivisor"] {
+		b.BeatDivisor = 4
+	}
+	if !b.SeenKeys["GridSize"] {
+		b.GridSize = 32
+	}
+	if !b.SeenKeys["TimelineZoom"] {
+		b.TimelineZoom = 1
+	}
+	// Old file formats have no approach rate, the overall difficulty is used instead.
+	b.HasApproachRate = b.SeenKeys["ApproachRate"]
+	if !b.HasApproachRate {
+		b.ApproachRate = b.OverallDifficulty
+	}
+}
+
 func newBeatmapParser() beatmapParser {
 	b := beatmapParser{}
 	b.Beatmap = newBeatmap()
 	b.EventLines = make([]string, 0)
+	b.VariableLines = make([]string, 0)
 	b.HitObjectLines = make([]string, 0)
 	b.TimingLines = make([]string, 0)
+	b.SeenKeys = make(map[string]bool)
 	return b
 }
+
+// Parse a comma-separated list of bookmark times.
+func parseBookmarks(str string) (bookmarks []int, err error) {
+	bookmarks = make([]int, 0)
+	for _, m := range strings.Split(str, ",") {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+		var t int
+		if t, err = strconv.Atoi(m); err != nil {
+			return
+		}
+		bookmarks = append(bookmarks, t)
+	}
+	return
+}
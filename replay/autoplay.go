@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"math"
+
+	parser "github.com/natsukagami/go-osu-parser"
+)
+
+// AutoplayOptions configures the autoplay cursor path generation.
+type AutoplayOptions struct {
+	// Spinner speed, in rotations per minute of real time. Defaults to the game's maximum of 477.
+	SpinnerRPM float64
+	// Time between two frames while moving, in ms. Defaults to 1000/60.
+	FrameInterval float64
+}
+
+// How long keys are held on circles, in ms.
+const autoplayKeyHold = 50
+
+// Radius of the circles drawn around the centre on spinners, in osu!pixels.
+const autoplaySpinRadius = 50
+
+// A frame at an absolute time.
+type autoFrame struct {
+	Time float64
+	Pos  parser.Point
+	Keys Keys
+}
+
+type autoplay struct {
+	opts   AutoplayOptions
+	rate   float64 // Playback rate of the mods
+	frames []autoFrame
+	key    Keys
+}
+
+// Autoplay generates the cursor path of a perfect play of the beatmap:
+// clicking every circle, following slider bodies along their paths and spinning
+// spinners at a constant speed.
+// The Easy and HardRock mods are applied to the beatmap, and stacking is taken
+// into account, so that the frames match what the game would show.
+// With rate-changing mods, spinners are spun at SpinnerRPM in real time.
+func Autoplay(b parser.Beatmap, mods parser.Mods, opts AutoplayOptions) []ReplayFrame {
+	if opts.SpinnerRPM <= 0 {
+		opts.SpinnerRPM = maxSpinsPerSecond * 60
+	}
+	if opts.FrameInterval <= 0 {
+		opts.FrameInterval = 1000.0 / 60
+	}
+	b = b.WithMods(mods)
+	a := autoplay{opts: opts, rate: mods.Rate(), key: M2 | K2}
+	a.add(0, spinnerCentre, 0)
+	for i, h := range b.HitObjects {
+		// Keys are released before the next object starts
+		release := math.Inf(1)
+		if i+1 < len(b.HitObjects) {
+			release = float64(b.HitObjects[i+1].StartTime) - 1
+		}
+		start := float64(h.StartTime)
+		switch h.ObjectName {
+		case parser.ObjectCircle:
+			a.moveTo(start, h.StackedPosition)
+			a.press(start, h.StackedPosition, math.Min(start+autoplayKeyHold, release))
+		case parser.ObjectSlider:
+			a.moveTo(start, h.StackedPosition)
+			a.slide(h, release)
+		case parser.ObjectSpinner:
+			a.moveTo(start, a.spinPosition(start, start))
+			a.spin(h, release)
+		}
+	}
+	return a.replayFrames()
+}
+
+// AutoplayReplay returns a replay of a perfect play of the beatmap, with the given mods.
+// Only the mode, mods, player name and frames are set.
+func AutoplayReplay(b parser.Beatmap, mods parser.Mods, opts AutoplayOptions) Replay {
+	return Replay{
+		Mode:       b.Mode,
+		PlayerName: "osu!",
+		Mods:       mods | parser.ModAutoplay,
+		LifeBar:    make([]LifeBarPoint, 0),
+		Frames:     Autoplay(b, mods, opts),
+	}
+}
+
+func (a *autoplay) add(time float64, pos parser.Point, keys Keys) {
+	if n := len(a.frames); n > 0 && a.frames[n-1].Time > time {
+		time = a.frames[n-1].Time
+	}
+	a.frames = append(a.frames, autoFrame{time, pos, keys})
+}
+
+func (a *autoplay) last() autoFrame {
+	return a.frames[len(a.frames)-1]
+}
+
+// Move the cursor in a straight line, with no keys pressed.
+func (a *autoplay) moveTo(time float64, pos parser.Point) {
+	from := a.last()
+	for t := from.Time + a.opts.FrameInterval; t < time; t += a.opts.FrameInterval {
+		p := (t - from.Time) / (time - from.Time)
+		a.add(t, parser.Point{X: from.Pos.X + (pos.X-from.Pos.X)*p, Y: from.Pos.Y + (pos.Y-from.Pos.Y)*p}, 0)
+	}
+}
+
+// Alternate between the two keys, pressing the next one.
+func (a *autoplay) nextKey() Keys {
+	if a.key == M1|K1 {
+		a.key = M2 | K2
+	} else {
+		a.key = M1 | K1
+	}
+	return a.key
+}
+
+// Click at a position, holding the key until the release time.
+func (a *autoplay) press(time float64, pos parser.Point, release float64) {
+	a.add(time, pos, a.nextKey())
+	a.add(math.Max(time+1, release), pos, 0)
+}
+
+// Follow a slider ball until the slider ends.
+func (a *autoplay) slide(h parser.HitObject, release float64) {
+	var (
+		path  = h.Path()
+		key   = a.nextKey()
+		start = float64(h.StartTime)
+		end   = float64(h.EndTime)
+	)
+	ball := func(t float64) parser.Point {
+		p := path.PositionAt(h.ProgressAt(t))
+		return parser.Point{X: p.X + h.StackedPosition.X - h.Position.X, Y: p.Y + h.StackedPosition.Y - h.Position.Y}
+	}
+	for t := start; t < end; t += a.opts.FrameInterval {
+		a.add(t, ball(t), key)
+	}
+	a.add(end, ball(end), key)
+	a.add(math.Max(end+1, math.Min(end+autoplayKeyHold, release)), ball(end), 0)
+}
+
+// Gets the cursor position when spinning.
+func (a *autoplay) spinPosition(start, t float64) parser.Point {
+	angle := (t - start) / a.rate / 60000 * a.opts.SpinnerRPM * 2 * math.Pi
+	return parser.Point{
+		X: spinnerCentre.X + autoplaySpinRadius*math.Cos(angle),
+		Y: spinnerCentre.Y + autoplaySpinRadius*math.Sin(angle),
+	}
+}
+
+// Spin around the centre until the spinner ends.
+func (a *autoplay) spin(h parser.HitObject, release float64) {
+	var (
+		key   = a.nextKey()
+		start = float64(h.StartTime)
+		end   = float64(h.EndTime)
+	)
+	for t := start; t < end; t += a.opts.FrameInterval {
+		a.add(t, a.spinPosition(start, t), key)
+	}
+	a.add(end, a.spinPosition(start, end), key)
+	a.add(math.Max(end+1, math.Min(end+autoplayKeyHold, release)), a.spinPosition(start, end), 0)
+}
+
+// Convert absolute times to rounded deltas.
+func (a *autoplay) replayFrames() []ReplayFrame {
+	frames := make([]ReplayFrame, 0, len(a.frames))
+	prev := 0
+	for _, f := range a.frames {
+		t := int(math.Floor(f.Time + 0.5))
+		frames = append(frames, ReplayFrame{Delta: t - prev, X: f.Pos.X, Y: f.Pos.Y, Keys: f.Keys})
+		prev = t
+	}
+	return frames
+}
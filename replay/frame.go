@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"strconv"
+	"strings"
+
+	parser "github.com/natsukagami/go-osu-parser"
+)
+
+// Keys is the bitflag set of keys pressed during a replay frame.
+// In osu!standard, K1 and K2 are always set along with M1 and M2 respectively.
+type Keys int
+
+// The keys that can be pressed.
+const (
+	M1 Keys = 1 << iota
+	M2
+	K1
+	K2
+	Smoke
+)
+
+// The delta of the frame holding the random number generator seed.
+const seedFrameDelta = -12345
+
+// ReplayFrame is a single input frame of a replay.
+type ReplayFrame struct {
+	Delta int     // Time since the previous frame, in ms
+	X     float64 // Cursor position, in osu!pixels
+	Y     float64
+	Keys  Keys // Pressed keys. In osu!mania, X holds the pressed columns instead.
+}
+
+// Parse a single "w|x|y|z" frame
+func parseFrame(str string) (f ReplayFrame, err error) {
+	members := strings.Split(str, "|")
+	if len(members) < 4 {
+		return f, parser.ParseError("Invalid replay frame: " + str)
+	}
+	var keys int
+	if f.Delta, err = strconv.Atoi(members[0]); err != nil {
+		return
+	}
+	if f.X, err = strconv.ParseFloat(members[1], 64); err != nil {
+		return
+	}
+	if f.Y, err = strconv.ParseFloat(members[2], 64); err != nil {
+		return
+	}
+	if keys, err = strconv.Atoi(members[3]); err != nil {
+		return
+	}
+	f.Keys = Keys(keys)
+	return
+}
+
+// Parse the decompressed frame data, splitting off the seed frame.
+func (r *Replay) parseFrames(data string) (err error) {
+	r.Frames = make([]ReplayFrame, 0)
+	for _, str := range strings.Split(data, ",") {
+		if str = strings.TrimSpace(str); len(str) == 0 {
+			continue
+		}
+		var f ReplayFrame
+		if f, err = parseFrame(str); err != nil {
+			return
+		}
+		if f.Delta == seedFrameDelta {
+			r.Seed = int(f.Keys)
+			r.HasSeed = true
+			continue
+		}
+		r.Frames = append(r.Frames, f)
+	}
+	return
+}
+
+// FrameTimes returns the absolute time (in ms) of every frame.
+func (r Replay) FrameTimes() []int {
+	times := make([]int, len(r.Frames))
+	t := 0
+	for i, f := range r.Frames {
+		t += f.Delta
+		times[i] = t
+	}
+	return times
+}
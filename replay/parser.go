@@ -0,0 +1,182 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Replays from this version onwards store the online score ID.
+const scoreIDVersion = 20121008
+
+// Replays from this version onwards store the online score ID as a long.
+const longScoreIDVersion = 20140721
+
+// ParseFile parses a replay given a filepath.
+func ParseFile(file string) (r Replay, err error) {
+	if stat, err := os.Stat(file); err != nil || stat.IsDir() {
+		return r, parser.ParseError("Invalid file")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// ParseBytes parses a replay given its contents as a byte array.
+func ParseBytes(b []byte) (r Replay, err error) {
+	return Parse(bytes.NewReader(b))
+}
+
+// Parse parses a replay from a reader.
+func Parse(reader io.Reader) (r Replay, err error) {
+	rd := osubin.NewReader(reader)
+	if err = r.parseHeader(rd); err != nil {
+		return
+	}
+	var length int32
+	if length, err = rd.Int(); err != nil {
+		return
+	}
+	// Scores without replay data (as in scores.db) have a length of -1
+	if length < -1 {
+		return r, parser.ParseError("Invalid replay data length")
+	}
+	var data []byte
+	if length > 0 {
+		if data, err = rd.Bytes(int(length)); err != nil {
+			return
+		}
+	}
+	if err = r.parseFrameData(data); err != nil {
+		return
+	}
+	switch {
+	case r.Version >= longScoreIDVersion:
+		if r.OnlineScoreID, err = rd.Long(); err != nil {
+			return
+		}
+	case r.Version >= scoreIDVersion:
+		var id int32
+		if id, err = rd.Int(); err != nil {
+			return
+		}
+		r.OnlineScoreID = int64(id)
+	}
+	if r.Mods.Has(parser.ModTarget) {
+		if r.TargetAccuracy, err = rd.Double(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Parse everything before the replay data.
+func (r *Replay) parseHeader(rd *osubin.Reader) (err error) {
+	var (
+		b     byte
+		i     int32
+		s     int16
+		count [6]int16
+	)
+	if b, err = rd.Byte(); err != nil {
+		return
+	}
+	r.Mode = int(b)
+	if i, err = rd.Int(); err != nil {
+		return
+	}
+	r.Version = int(i)
+	if r.BeatmapMD5, err = rd.String(); err != nil {
+		return
+	}
+	if r.PlayerName, err = rd.String(); err != nil {
+		return
+	}
+	if r.ReplayMD5, err = rd.String(); err != nil {
+		return
+	}
+	for j := range count {
+		if count[j], err = rd.Short(); err != nil {
+			return
+		}
+	}
+	// Counts are unsigned, like the max combo
+	r.Count300, r.Count100, r.Count50 = int(uint16(count[0])), int(uint16(count[1])), int(uint16(count[2]))
+	r.CountGeki, r.CountKatu, r.CountMiss = int(uint16(count[3])), int(uint16(count[4])), int(uint16(count[5]))
+	if i, err = rd.Int(); err != nil {
+		return
+	}
+	r.Score = int(i)
+	if s, err = rd.Short(); err != nil {
+		return
+	}
+	r.MaxCombo = int(uint16(s))
+	if r.Perfect, err = rd.Bool(); err != nil {
+		return
+	}
+	if i, err = rd.Int(); err != nil {
+		return
+	}
+	r.Mods = parser.Mods(i)
+	var lifeBar string
+	if lifeBar, err = rd.String(); err != nil {
+		return
+	}
+	if r.LifeBar, err = parseLifeBar(lifeBar); err != nil {
+		return
+	}
+	if r.Timestamp, err = rd.Time(); err != nil {
+		return
+	}
+	return
+}
+
+// Parse the "time|life" pairs of the life bar graph
+func parseLifeBar(str string) (l []LifeBarPoint, err error) {
+	l = make([]LifeBarPoint, 0)
+	for _, pair := range strings.Split(str, ",") {
+		if len(pair) == 0 {
+			continue
+		}
+		members := strings.Split(pair, "|")
+		if len(members) < 2 {
+			return nil, parser.ParseError("Invalid life bar point: " + pair)
+		}
+		p := LifeBarPoint{}
+		if p.Time, err = strconv.Atoi(members[0]); err != nil {
+			return
+		}
+		if p.Life, err = strconv.ParseFloat(members[1], 64); err != nil {
+			return
+		}
+		l = append(l, p)
+	}
+	return
+}
+
+// Decompress and parse the LZMA-compressed replay frames.
+func (r *Replay) parseFrameData(data []byte) (err error) {
+	r.Frames = make([]ReplayFrame, 0)
+	if len(data) == 0 {
+		return
+	}
+	var lr io.Reader
+	if lr, err = lzma.NewReader(bytes.NewReader(data)); err != nil {
+		return
+	}
+	var raw []byte
+	if raw, err = ioutil.ReadAll(lr); err != nil {
+		return
+	}
+	return r.parseFrames(string(raw))
+}
@@ -0,0 +1,62 @@
+// Package replay provides an .osr replay parser.
+// Replays can be paired with a parsed beatmap (see the parser package)
+// to analyse plays offline.
+package replay
+
+import (
+	"strings"
+	"time"
+
+	parser "github.com/natsukagami/go-osu-parser"
+)
+
+// Replay represents an osu! replay.
+type Replay struct {
+	Mode          int
+	Version       int // Game version, e.g. 20150414
+	BeatmapMD5    string
+	PlayerName    string
+	ReplayMD5     string
+	Count300      int
+	Count100      int
+	Count50       int
+	CountGeki     int
+	CountKatu     int
+	CountMiss     int
+	Score         int
+	MaxCombo      int
+	Perfect       bool
+	Mods          parser.Mods
+	LifeBar       []LifeBarPoint
+	Timestamp     time.Time
+	OnlineScoreID int64 // Zero if not submitted. Not stored before version 20121008.
+	// Accuracy of the play, only present with the Target Practice mod.
+	TargetAccuracy float64
+	Frames         []ReplayFrame
+	// The seed of the random number generator, stored by the game as the last frame.
+	// HasSeed is false for old replays with no seed frame.
+	Seed    int
+	HasSeed bool
+}
+
+// LifeBarPoint is a point on the life bar graph of a replay.
+type LifeBarPoint struct {
+	Time int     // in ms
+	Life float64 // from 0 to 1
+}
+
+// Matches returns whether the replay was played on the given beatmap.
+func (r Replay) Matches(b parser.Beatmap) bool {
+	return r.BeatmapMD5 != "" && strings.EqualFold(r.BeatmapMD5, b.MD5)
+}
+
+// Judgements returns the judgement counts and max combo of the replay.
+func (r Replay) Judgements() parser.Judgements {
+	return parser.Judgements{
+		Count300:  r.Count300,
+		Count100:  r.Count100,
+		Count50:   r.Count50,
+		CountMiss: r.CountMiss,
+		MaxCombo:  r.MaxCombo,
+	}
+}
@@ -0,0 +1,284 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+)
+
+func TestRoundTrip(t *testing.T) {
+	r := Replay{
+		Mode:          0,
+		Version:       20190906,
+		BeatmapMD5:    "c8f08438204abfcdd1a748ebfae67421",
+		PlayerName:    "peppy",
+		ReplayMD5:     "e0f5e5f6d12e8e6a3bc3e3e9e3d4b2a1",
+		Count300:      40200, // Above the signed 16 bit range
+		Count100:      12,
+		Count50:       1,
+		CountGeki:     80,
+		CountKatu:     9,
+		CountMiss:     2,
+		Score:         4851230,
+		MaxCombo:      388,
+		Mods:          parser.ModHidden | parser.ModHardRock,
+		LifeBar:       []LifeBarPoint{{Time: 2000, Life: 1}, {Time: 4000, Life: 0.75}},
+		Timestamp:     time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC),
+		OnlineScoreID: 2900000000,
+		Frames: []ReplayFrame{
+			{Delta: 0, X: 256, Y: -500},
+			{Delta: -1, X: 256, Y: -500},
+			{Delta: 16, X: 100.5, Y: 200.25, Keys: M1 | K1},
+			{Delta: 17, X: 101, Y: 199, Keys: 0},
+		},
+		Seed:    1597,
+		HasSeed: true,
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, got) {
+		t.Errorf("Replay changed after round trip:\nwant %+v\n got %+v", r, got)
+	}
+}
+
+func TestParseVersions(t *testing.T) {
+	for _, test := range []struct {
+		Version int
+		IDSize  int // Size of the stored online score ID
+	}{
+		{20120508, 0},
+		{20121008, 4},
+		{20140520, 4},
+		{20140721, 8},
+		{20210520, 8},
+	} {
+		var buf bytes.Buffer
+		w := osubin.NewWriter(&buf)
+		w.Byte(0)
+		w.Int(int32(test.Version))
+		w.String("c8f08438204abfcdd1a748ebfae67421")
+		w.String("peppy")
+		w.String("e0f5e5f6d12e8e6a3bc3e3e9e3d4b2a1")
+		for _, count := range []int16{300, 20, 3, 60, 10, 1} {
+			w.Short(count)
+		}
+		w.Int(1234567)
+		w.Short(321)
+		w.Bool(false)
+		w.Int(int32(parser.ModHidden))
+		w.String("")
+		w.Time(time.Date(2012, 10, 8, 0, 0, 0, 0, time.UTC))
+		w.Int(0)
+		switch test.IDSize {
+		case 4:
+			w.Int(123456)
+		case 8:
+			w.Long(123456)
+		}
+		if err := w.Err(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := ParseBytes(buf.Bytes())
+		if err != nil {
+			t.Errorf("Version %d: %v", test.Version, err)
+			continue
+		}
+		wantID := int64(123456)
+		if test.IDSize == 0 {
+			wantID = 0
+		}
+		if r.Version != test.Version || r.PlayerName != "peppy" || r.Count300 != 300 || r.CountMiss != 1 ||
+			r.Score != 1234567 || r.MaxCombo != 321 || r.Mods != parser.ModHidden || r.OnlineScoreID != wantID {
+			t.Errorf("Version %d: unexpected replay %+v", test.Version, r)
+		}
+		b, err := r.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseBytes(b); err != nil || !reflect.DeepEqual(got, r) {
+			t.Errorf("Version %d: replay changed after round trip (%v):\nwant %+v\n got %+v", test.Version, err, r, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, test := range []struct {
+		Name string
+		Data []byte
+		Err  error
+	}{
+		// A 4GB beatmap MD5 in a truncated file
+		{"long string", []byte{0, 0x5f, 0xb8, 0x32, 0x01, 0x0b, 0x80, 0x80, 0x80, 0x80, 0x10, 'a'}, io.ErrUnexpectedEOF},
+		// A string length longer than 64 bits
+		{"overflow", append([]byte{0, 0x5f, 0xb8, 0x32, 0x01, 0x0b}, bytes.Repeat([]byte{0xff}, 11)...), osubin.ErrOverflow},
+	} {
+		if _, err := ParseBytes(test.Data); err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	r := Result{Objects: []ObjectResult{
+		{Index: 0, Time: 1000, Clicked: true, HitError: -10, Judgement: Hit300},
+		{Index: 1, Time: 1500, Clicked: true, HitError: 10, Judgement: Hit300},
+		{Index: 2, Time: 2000, Judgement: Miss},
+		{Index: 3, Time: 2500, Clicked: true, HitError: -30, Judgement: Hit100},
+		{Index: 4, Time: 3000, Clicked: true, HitError: 30, Judgement: Hit100},
+	}}
+	s := r.Stats()
+	if s.Count != 4 || s.Mean != 0 || s.MeanEarly != -20 || s.MeanLate != 20 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if math.Abs(s.UnstableRate-math.Sqrt(500)*10) > 1e-9 {
+		t.Errorf("Expected unstable rate %v, got %v", math.Sqrt(500)*10, s.UnstableRate)
+	}
+	if sections := r.SectionStats(2000); len(sections) != 2 || sections[0].Stats.Count != 2 || sections[1].Stats.Count != 2 {
+		t.Errorf("Unexpected sections %+v", sections)
+	}
+	bins := Histogram(r.HitOffsets(), 20)
+	want := []HistogramBin{{-40, 1}, {-20, 1}, {0, 1}, {20, 1}}
+	if !reflect.DeepEqual(bins, want) {
+		t.Errorf("Expected histogram %v, got %v", want, bins)
+	}
+}
+
+func TestAutoplay(t *testing.T) {
+	for _, mods := range []parser.Mods{0, parser.ModHardRock, parser.ModEasy, parser.ModHalfTime} {
+		for _, file := range []string{"../testfiles/v10.osu", "../testfiles/v14.osu"} {
+			b, err := parser.ParseFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := Simulate(b, AutoplayReplay(b, mods, AutoplayOptions{}))
+			if j := res.Judgements; j.Count300 != len(b.HitObjects) {
+				t.Errorf("%s (%v): expected %d 300s, got %+v", file, mods, len(b.HitObjects), j)
+			}
+		}
+	}
+}
+
+// A minimal osu!standard beatmap with 100 osu!pixels per 500ms beat.
+// At OD5, the 300, 100 and 50 windows are 50, 100 and 150ms.
+const testBeatmapHeader = `osu file format v14
+
+[General]
+Mode: 0
+
+[Difficulty]
+HPDrainRate:5
+CircleSize:4
+OverallDifficulty:5
+ApproachRate:9
+SliderMultiplier:1
+SliderTickRate:1
+
+[TimingPoints]
+0,500,4,2,0,100,1,0
+
+[HitObjects]
+`
+
+// A frame at an absolute time.
+type testFrame struct {
+	Time int
+	X, Y float64
+	Keys Keys
+}
+
+func testReplay(mods parser.Mods, frames []testFrame) Replay {
+	r := Replay{Mods: mods, Frames: make([]ReplayFrame, len(frames))}
+	prev := 0
+	for i, f := range frames {
+		r.Frames[i] = ReplayFrame{Delta: f.Time - prev, X: f.X, Y: f.Y, Keys: f.Keys}
+		prev = f.Time
+	}
+	return r
+}
+
+func TestSimulate(t *testing.T) {
+	b, err := parser.ParseString(testBeatmapHeader + `100,100,1000,1,0
+200,100,2000,1,0
+300,100,3000,1,0
+400,100,4000,1,0
+100,300,5000,1,0
+300,300,5100,1,0
+100,200,7000,2,0,L|300:200,1,200
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []testFrame{
+		{1000, 100, 100, M1}, {1030, 100, 100, 0}, // 300
+		{2080, 200, 100, M1}, {2110, 200, 100, 0}, // 100
+		{3130, 300, 100, M1}, {3160, 300, 100, 0}, // 50
+		// The circle at 4000 is never clicked
+		// The circle at 5000 locks the one at 5100 until its 50 window has passed
+		{4990, 300, 300, M1}, {5020, 300, 300, 0},
+		{5100, 300, 300, M1}, {5130, 300, 300, 0},
+		{5160, 300, 300, M1}, {5190, 300, 300, 0},
+	}
+	// The slider is followed, then dropped before its tick
+	for time := 7000; time <= 7400; time += 100 {
+		frames = append(frames, testFrame{time, 100 + float64(time-7000)/5, 200, M1})
+	}
+	frames = append(frames, testFrame{7450, 190, 200, 0}, testFrame{8500, 300, 200, 0})
+
+	res := Simulate(b, testReplay(0, frames))
+	want := []Judgement{Hit300, Hit100, Hit50, Miss, Miss, Hit100, Hit50}
+	for i, o := range res.Objects {
+		if o.Judgement != want[i] {
+			t.Errorf("Object %d: expected %v, got %+v", i, want[i], o)
+		}
+	}
+	if o := res.Objects[5]; !o.Clicked || o.HitError != 60 {
+		t.Errorf("Expected the notelocked circle to be hit 60ms late, got %+v", o)
+	}
+	if o := res.Objects[6]; !o.Clicked || o.SliderPartsHit != 1 || o.SliderPartsTotal != 3 || o.Combo != 0 {
+		t.Errorf("Expected the slider to be dropped after its head, got %+v", o)
+	}
+	j := res.Judgements
+	if j.Count300 != 1 || j.Count100 != 2 || j.Count50 != 2 || j.CountMiss != 2 || j.MaxCombo != 3 {
+		t.Errorf("Unexpected judgements %+v", j)
+	}
+}
+
+func TestSimulateRate(t *testing.T) {
+	// 2 seconds at OD5 need 10 rotations
+	b, err := parser.ParseString(testBeatmapHeader + "256,192,1000,12,0,3000\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spinning much faster than the limit, which is in real time
+	frames := make([]testFrame, 0)
+	for time := 1000; time <= 3000; time += 10 {
+		angle := float64(time) / 1000 * 2 * math.Pi * 2000 / 60
+		frames = append(frames, testFrame{time, 256 + 50*math.Cos(angle), 192 + 50*math.Sin(angle), M1})
+	}
+	for _, test := range []struct {
+		Mods      parser.Mods
+		Rotations float64
+	}{
+		{0, 2 * maxSpinsPerSecond},
+		{parser.ModDoubleTime, 2 * maxSpinsPerSecond / 1.5},
+		{parser.ModDoubleTime | parser.ModNightcore, 2 * maxSpinsPerSecond / 1.5},
+		{parser.ModHalfTime, 2 * maxSpinsPerSecond / 0.75},
+	} {
+		o := Simulate(b, testReplay(test.Mods, frames)).Objects[0]
+		if math.Abs(o.Rotations-test.Rotations) > 1e-6 || math.Abs(o.RPM-477) > 1e-6 || o.Judgement != Hit300 {
+			t.Errorf("%v: expected %v rotations at 477 RPM, got %+v", test.Mods, test.Rotations, o)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package replay
+
+import (
+	"math"
+	"sort"
+
+	parser "github.com/natsukagami/go-osu-parser"
+)
+
+// Judgement is the result of playing a hit object.
+type Judgement int
+
+// The possible judgements.
+const (
+	Miss Judgement = iota
+	Hit50
+	Hit100
+	Hit300
+)
+
+func (j Judgement) String() string {
+	switch j {
+	case Hit50:
+		return "50"
+	case Hit100:
+		return "100"
+	case Hit300:
+		return "300"
+	}
+	return "miss"
+}
+
+// ObjectResult is the simulated judgement of a single hit object.
+type ObjectResult struct {
+	Index     int // Index of the object in the beatmap's HitObjects
+	Time      int // Start time of the object
+	Judgement Judgement
+	Clicked   bool // Whether the circle or slider head was hit
+	HitError  int  // Click time minus the object's start time, in ms, if Clicked
+	Combo     int  // Combo after the object
+	// Sliders only: the number of head, ticks, repeats and tail hit, out of the total.
+	SliderPartsHit   int
+	SliderPartsTotal int
+	// Spinners only
+	Rotations float64
+	RPM       float64 // Average rotations per minute, in real time
+}
+
+// Result is the outcome of a simulated play.
+type Result struct {
+	Objects    []ObjectResult
+	Judgements parser.Judgements
+	Accuracy   float64
+}
+
+// Radius multiplier of the follow circle once a slider is being tracked.
+const followCircleScale = 2.4
+
+// Spinners cannot be spun faster than this, in rotations per second.
+const maxSpinsPerSecond = 477.0 / 60
+
+// The centre of the playfield, where spinners are.
+var spinnerCentre = parser.Point{X: 256, Y: 192}
+
+// A timed input state of the replay.
+type cursorState struct {
+	Time float64
+	Pos  parser.Point
+	Keys Keys // M1 and M2 only
+}
+
+// A key press.
+type press struct {
+	Time float64
+	Pos  parser.Point
+}
+
+// A change of combo caused by a judged object part.
+type comboEvent struct {
+	Time     float64
+	Object   int
+	Increase bool
+	Break    bool
+}
+
+type simulator struct {
+	b       parser.Beatmap
+	states  []cursorState
+	radius  float64
+	windows parser.HitWindows
+	rate    float64 // Playback rate of the mods
+	results []ObjectResult
+	combo   []comboEvent
+}
+
+// Simulate re-plays the replay on the beatmap, judging every hit object.
+// The Easy and HardRock mods of the replay are applied to the beatmap.
+// Only osu!standard is supported: other modes give an empty result.
+//
+// Replay frames are timed in song time, in which the game applies the hit windows
+// unchanged, so rate-changing mods (DoubleTime, Nightcore, HalfTime) only change
+// the spinning speed limit, which is in real time.
+//
+// The simulation follows the game's rules closely but not exactly:
+// clicks hit the earliest object that is still hittable (notelock),
+// sliders are tracked along their exact paths, and spinners are judged
+// on the rotations made while a key is held.
+func Simulate(b parser.Beatmap, r Replay) Result {
+	if b.Mode != 0 || r.Mode != 0 {
+		return Result{Objects: make([]ObjectResult, 0), Judgements: parser.Judgements{}, Accuracy: 1}
+	}
+	b = b.WithMods(r.Mods)
+	s := simulator{
+		b:       b,
+		states:  cursorStates(r),
+		radius:  b.CircleRadius(),
+		windows: b.HitWindows(),
+		rate:    r.Mods.Rate(),
+		results: make([]ObjectResult, len(b.HitObjects)),
+	}
+	for i := range s.results {
+		s.results[i].Index = i
+		s.results[i].Time = b.HitObjects[i].StartTime
+	}
+	s.judgeClicks()
+	for i, h := range b.HitObjects {
+		switch h.ObjectName {
+		case parser.ObjectCircle:
+			s.addCombo(float64(h.StartTime), i, s.results[i].Clicked, false)
+		case parser.ObjectSlider:
+			s.judgeSlider(i)
+		case parser.ObjectSpinner:
+			s.judgeSpinner(i)
+		}
+	}
+	return s.result()
+}
+
+// Converts replay frames to absolute times, keeping only the mouse buttons
+// (keyboard keys always come with their mouse button).
+func cursorStates(r Replay) []cursorState {
+	states := make([]cursorState, 0, len(r.Frames))
+	t := 0.0
+	for _, f := range r.Frames {
+		t += float64(f.Delta)
+		states = append(states, cursorState{t, parser.Point{X: f.X, Y: f.Y}, f.Keys & (M1 | M2)})
+	}
+	sort.SliceStable(states, func(i, j int) bool { return states[i].Time < states[j].Time })
+	return states
+}
+
+// Gets the index of the last state at or before the given time, or -1.
+func (s *simulator) stateAt(time float64) int {
+	return sort.Search(len(s.states), func(i int) bool { return s.states[i].Time > time }) - 1
+}
+
+// Gets every key press of the replay.
+func (s *simulator) presses() []press {
+	var (
+		presses = make([]press, 0)
+		prev    Keys
+	)
+	for _, st := range s.states {
+		pressed := st.Keys &^ prev
+		for _, k := range []Keys{M1, M2} {
+			if pressed&k != 0 {
+				presses = append(presses, press{st.Time, st.Pos})
+			}
+		}
+		prev = st.Keys
+	}
+	return presses
+}
+
+// Judge the clicks on circles and slider heads.
+func (s *simulator) judgeClicks() {
+	var (
+		objs     = s.b.HitObjects
+		next     = 0 // The first object that may still be clicked
+		judged   = make([]bool, len(objs))
+		clicking = func(i int) bool {
+			return objs[i].ObjectName == parser.ObjectCircle || objs[i].ObjectName == parser.ObjectSlider
+		}
+	)
+	for _, p := range s.presses() {
+		// Objects whose hit window has passed are missed
+		for next < len(objs) && (!clicking(next) || judged[next] || float64(objs[next].StartTime)+s.windows.Hit50 < p.Time) {
+			next++
+		}
+		if next == len(objs) {
+			break
+		}
+		h := objs[next]
+		offset := p.Time - float64(h.StartTime)
+		if offset < -s.windows.Miss {
+			continue // Too early, ignored
+		}
+		// Clicks outside the earliest object are ignored, even if they are on a later object.
+		if distance(p.Pos, h.StackedPosition) > s.radius {
+			continue
+		}
+		judged[next] = true
+		res := &s.results[next]
+		res.HitError = int(math.Floor(offset + 0.5))
+		switch abs := math.Abs(offset); {
+		case abs <= s.windows.Hit300:
+			res.Judgement = Hit300
+		case abs <= s.windows.Hit100:
+			res.Judgement = Hit100
+		case abs <= s.windows.Hit50:
+			res.Judgement = Hit50
+		default:
+			res.Judgement = Miss // Clicked too early
+			continue
+		}
+		res.Clicked = true
+	}
+}
+
+func distance(a, b parser.Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// Judge the body of a slider, after its head has been judged.
+func (s *simulator) judgeSlider(i int) {
+	var (
+		h      = s.b.HitObjects[i]
+		res    = &s.results[i]
+		path   = h.Path()
+		stack  = parser.Point{X: h.StackedPosition.X - h.Position.X, Y: h.StackedPosition.Y - h.Position.Y}
+		events = s.b.SliderEvents(h)
+	)
+	ball := func(time float64) parser.Point {
+		p := path.PositionAt(h.ProgressAt(time))
+		return parser.Point{X: p.X + stack.X, Y: p.Y + stack.Y}
+	}
+	s.addCombo(float64(h.StartTime), i, res.Clicked, false)
+	hits := 0
+	if res.Clicked {
+		hits++
+	}
+	if len(events) > 0 {
+		events = events[1:] // The head was judged by clicking
+	}
+	for j := range events {
+		if events[j].Type == parser.SliderTail {
+			events[j].Time = h.LegacyTailTime()
+		}
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Time < events[b].Time })
+
+	var (
+		tracking = false
+		st       = s.stateAt(float64(h.StartTime))
+		update   = func(time float64, c cursorState) {
+			r := s.radius
+			if tracking {
+				r *= followCircleScale
+			}
+			tracking = c.Keys != 0 && distance(c.Pos, ball(time)) <= r
+		}
+	)
+	if st < 0 {
+		st = 0
+	}
+	for _, e := range events {
+		for ; st < len(s.states) && s.states[st].Time <= e.Time; st++ {
+			if s.states[st].Time >= float64(h.StartTime) {
+				update(s.states[st].Time, s.states[st])
+			}
+		}
+		if st > 0 {
+			update(e.Time, s.states[st-1])
+		}
+		if tracking {
+			hits++
+		}
+		// Missing the tail does not break combo
+		s.addCombo(e.Time, i, tracking, e.Type == parser.SliderTail)
+	}
+
+	res.SliderPartsHit = hits
+	res.SliderPartsTotal = len(events) + 1
+	switch {
+	case hits == res.SliderPartsTotal:
+		res.Judgement = Hit300
+	case hits*2 >= res.SliderPartsTotal:
+		res.Judgement = Hit100
+	case hits > 0:
+		res.Judgement = Hit50
+	default:
+		res.Judgement = Miss
+	}
+}
+
+// Judge a spinner from the rotations made while holding a key.
+func (s *simulator) judgeSpinner(i int) {
+	var (
+		h        = s.b.HitObjects[i]
+		res      = &s.results[i]
+		start    = float64(h.StartTime)
+		end      = float64(h.EndTime)
+		rotation = 0.0
+		st       = s.stateAt(start)
+	)
+	if st < 0 {
+		st = 0
+	}
+	for ; st+1 < len(s.states) && s.states[st+1].Time <= end; st++ {
+		prev, cur := s.states[st], s.states[st+1]
+		if cur.Keys == 0 || prev.Keys == 0 || cur.Time < start {
+			continue
+		}
+		var (
+			a1 = math.Atan2(prev.Pos.Y-spinnerCentre.Y, prev.Pos.X-spinnerCentre.X)
+			a2 = math.Atan2(cur.Pos.Y-spinnerCentre.Y, cur.Pos.X-spinnerCentre.X)
+			d  = math.Remainder(a2-a1, 2*math.Pi)
+			// Spinning faster than the limit does not count
+			limit = maxSpinsPerSecond * 2 * math.Pi * (cur.Time - prev.Time) / s.rate / 1000
+		)
+		rotation += math.Max(-limit, math.Min(limit, d))
+	}
+	res.Rotations = math.Abs(rotation) / (2 * math.Pi)
+	if end > start {
+		res.RPM = res.Rotations / ((end - start) / s.rate / 60000)
+	}
+	progress := 1.0
+	if required := (end - start) / 1000 * s.b.SpinsPerSecond(); required > 0 {
+		progress = res.Rotations / required
+	}
+	switch {
+	case progress >= 1:
+		res.Judgement = Hit300
+	case progress > 0.9:
+		res.Judgement = Hit100
+	case progress > 0.75:
+		res.Judgement = Hit50
+	default:
+		res.Judgement = Miss
+	}
+	s.addCombo(end, i, res.Judgement != Miss, false)
+}
+
+func (s *simulator) addCombo(time float64, object int, hit, keepCombo bool) {
+	s.combo = append(s.combo, comboEvent{
+		Time:     time,
+		Object:   object,
+		Increase: hit,
+		Break:    !hit && !keepCombo,
+	})
+}
+
+// Compute combo and judgement counts.
+func (s *simulator) result() Result {
+	sort.SliceStable(s.combo, func(i, j int) bool { return s.combo[i].Time < s.combo[j].Time })
+	var j parser.Judgements
+	combo := 0
+	for _, e := range s.combo {
+		if e.Increase {
+			combo++
+		} else if e.Break {
+			combo = 0
+		}
+		if combo > j.MaxCombo {
+			j.MaxCombo = combo
+		}
+		s.results[e.Object].Combo = combo
+	}
+	for _, res := range s.results {
+		switch res.Judgement {
+		case Hit300:
+			j.Count300++
+		case Hit100:
+			j.Count100++
+		case Hit50:
+			j.Count50++
+		default:
+			j.CountMiss++
+		}
+	}
+	return Result{Objects: s.results, Judgements: j, Accuracy: j.Accuracy()}
+}
@@ -0,0 +1,143 @@
+package replay
+
+import (
+	"math"
+	"sort"
+)
+
+// HitOffset is the hit error of a single clicked object.
+type HitOffset struct {
+	Index     int       `json:"index"`     // Index of the object in the beatmap's HitObjects
+	Time      int       `json:"time"`      // Start time of the object
+	Offset    int       `json:"offset"`    // Negative when early, positive when late, in ms
+	Judgement Judgement `json:"judgement"` // 0 (miss) to 3 (300)
+}
+
+// HitErrorStats summarises a set of hit errors.
+type HitErrorStats struct {
+	Count        int
+	UnstableRate float64 // 10 times the standard deviation of the hit errors
+	Mean         float64 // Mean hit error, negative when early
+	MeanEarly    float64 // Mean of the early hit errors
+	MeanLate     float64 // Mean of the late (or exact) hit errors
+	Min          int
+	Max          int
+}
+
+// Section holds the hit error statistics of a part of a play.
+type Section struct {
+	Start int // Inclusive, in ms
+	End   int // Exclusive, in ms
+	Stats HitErrorStats
+}
+
+// HistogramBin counts the hit errors within [Start, Start + width).
+type HistogramBin struct {
+	Start int
+	Count int
+}
+
+// HitOffsets returns the hit errors of every clicked circle and slider head.
+func (r Result) HitOffsets() []HitOffset {
+	offsets := make([]HitOffset, 0)
+	for _, o := range r.Objects {
+		if o.Clicked {
+			offsets = append(offsets, HitOffset{o.Index, o.Time, o.HitError, o.Judgement})
+		}
+	}
+	return offsets
+}
+
+// Stats returns the hit error statistics of the whole play.
+func (r Result) Stats() HitErrorStats {
+	return ComputeStats(r.HitOffsets())
+}
+
+// SectionStats splits the play into sections of the given length (in ms),
+// starting from time 0, and returns the statistics of every section
+// up to the last clicked object.
+func (r Result) SectionStats(length int) []Section {
+	sections := make([]Section, 0)
+	if length <= 0 {
+		return sections
+	}
+	var (
+		offsets = r.HitOffsets()
+		start   = 0
+	)
+	for len(offsets) > 0 {
+		end := start + length
+		i := sort.Search(len(offsets), func(i int) bool { return offsets[i].Time >= end })
+		sections = append(sections, Section{start, end, ComputeStats(offsets[:i])})
+		offsets = offsets[i:]
+		start = end
+	}
+	return sections
+}
+
+// ComputeStats computes the statistics of a set of hit errors.
+func ComputeStats(offsets []HitOffset) (s HitErrorStats) {
+	s.Count = len(offsets)
+	if s.Count == 0 {
+		return
+	}
+	var (
+		sum, sumEarly, sumLate float64
+		early, late            int
+	)
+	s.Min, s.Max = offsets[0].Offset, offsets[0].Offset
+	for _, o := range offsets {
+		sum += float64(o.Offset)
+		if o.Offset < 0 {
+			sumEarly += float64(o.Offset)
+			early++
+		} else {
+			sumLate += float64(o.Offset)
+			late++
+		}
+		if o.Offset < s.Min {
+			s.Min = o.Offset
+		}
+		if o.Offset > s.Max {
+			s.Max = o.Offset
+		}
+	}
+	s.Mean = sum / float64(s.Count)
+	if early > 0 {
+		s.MeanEarly = sumEarly / float64(early)
+	}
+	if late > 0 {
+		s.MeanLate = sumLate / float64(late)
+	}
+	variance := 0.0
+	for _, o := range offsets {
+		variance += (float64(o.Offset) - s.Mean) * (float64(o.Offset) - s.Mean)
+	}
+	s.UnstableRate = math.Sqrt(variance/float64(s.Count)) * 10
+	return
+}
+
+// Histogram counts the hit errors in bins of the given width (in ms).
+// Bins go from the smallest to the largest hit error, including empty ones.
+func Histogram(offsets []HitOffset, width int) []HistogramBin {
+	bins := make([]HistogramBin, 0)
+	if len(offsets) == 0 || width <= 0 {
+		return bins
+	}
+	s := ComputeStats(offsets)
+	// Round down towards negative infinity
+	floor := func(x int) int {
+		if x < 0 {
+			return -((-x + width - 1) / width) * width
+		}
+		return x / width * width
+	}
+	first := floor(s.Min)
+	for start := first; start <= s.Max; start += width {
+		bins = append(bins, HistogramBin{Start: start})
+	}
+	for _, o := range offsets {
+		bins[(floor(o.Offset)-first)/width].Count++
+	}
+	return bins
+}
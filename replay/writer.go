@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	parser "github.com/natsukagami/go-osu-parser"
+	"github.com/natsukagami/go-osu-parser/internal/osubin"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// WriteFile writes the replay as an .osr file.
+func (r Replay) WriteFile(file string) error {
+	b, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// Bytes returns the replay encoded in the .osr format.
+func (r Replay) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write encodes the replay in the .osr format.
+// The frames (and the seed frame, if HasSeed is set) are LZMA-compressed.
+func (r Replay) Write(writer io.Writer) error {
+	data, err := r.compressFrames()
+	if err != nil {
+		return err
+	}
+	w := osubin.NewWriter(writer)
+	w.Byte(byte(r.Mode))
+	w.Int(int32(r.Version))
+	w.String(r.BeatmapMD5)
+	w.String(r.PlayerName)
+	w.String(r.ReplayMD5)
+	for _, count := range []int{r.Count300, r.Count100, r.Count50, r.CountGeki, r.CountKatu, r.CountMiss} {
+		w.Short(int16(uint16(count)))
+	}
+	w.Int(int32(r.Score))
+	w.Short(int16(uint16(r.MaxCombo)))
+	w.Bool(r.Perfect)
+	w.Int(int32(r.Mods))
+	w.String(r.lifeBarString())
+	w.Time(r.Timestamp)
+	w.Int(int32(len(data)))
+	w.Bytes(data)
+	switch {
+	case r.Version >= longScoreIDVersion:
+		w.Long(r.OnlineScoreID)
+	case r.Version >= scoreIDVersion:
+		w.Int(int32(r.OnlineScoreID))
+	}
+	if r.Mods.Has(parser.ModTarget) {
+		w.Double(r.TargetAccuracy)
+	}
+	return w.Err()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Encode the life bar graph as "time|life" pairs.
+func (r Replay) lifeBarString() string {
+	var buf bytes.Buffer
+	for _, p := range r.LifeBar {
+		buf.WriteString(strconv.Itoa(p.Time))
+		buf.WriteByte('|')
+		buf.WriteString(formatFloat(p.Life))
+		buf.WriteByte(',')
+	}
+	return buf.String()
+}
+
+// Encode the frames as "w|x|y|z" text.
+func (r Replay) framesString() string {
+	var buf bytes.Buffer
+	write := func(f ReplayFrame) {
+		buf.WriteString(strconv.Itoa(f.Delta))
+		buf.WriteByte('|')
+		buf.WriteString(formatFloat(f.X))
+		buf.WriteByte('|')
+		buf.WriteString(formatFloat(f.Y))
+		buf.WriteByte('|')
+		buf.WriteString(strconv.Itoa(int(f.Keys)))
+		buf.WriteByte(',')
+	}
+	for _, f := range r.Frames {
+		write(f)
+	}
+	if r.HasSeed {
+		write(ReplayFrame{Delta: seedFrameDelta, Keys: Keys(r.Seed)})
+	}
+	return buf.String()
+}
+
+// Compress the frames with LZMA, storing the uncompressed size in the header
+// as the game does.
+func (r Replay) compressFrames() ([]byte, error) {
+	text := r.framesString()
+	if text == "" {
+		return nil, nil // Stored as empty data, like scores without a replay
+	}
+	var buf bytes.Buffer
+	cfg := lzma.WriterConfig{SizeInHeader: true, Size: int64(len(text))}
+	lw, err := cfg.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(lw, text); err != nil {
+		return nil, err
+	}
+	if err := lw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
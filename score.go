@@ -0,0 +1,154 @@
+package parser
+
+import "math"
+
+// Judgements holds the judgement counts of a play.
+type Judgements struct {
+	Count300  int
+	Count100  int
+	Count50   int
+	CountMiss int
+	MaxCombo  int
+}
+
+// Accuracy returns the osu!standard accuracy of the judgements, from 0 to 1.
+func (j Judgements) Accuracy() float64 {
+	total := j.Count300 + j.Count100 + j.Count50 + j.CountMiss
+	if total == 0 {
+		return 1
+	}
+	return float64(300*j.Count300+100*j.Count100+50*j.Count50) / float64(300*total)
+}
+
+// Gets the number of ticks on each span of a slider.
+func (b Beatmap) sliderTicksPerSpan(h HitObject) int {
+	tp := b.getTimingPoint(float64(h.StartTime))
+	if tp == nil || b.SliderTickRate == 0 {
+		return 0
+	}
+	var (
+		osupxPerBeat = b.SliderMultiplier * 100 * tp.Velocity
+		tickLength   = osupxPerBeat / float64(b.SliderTickRate)
+		ticks        = int(math.Ceil((math.Floor(h.PixelLength/tickLength*100) / 100) - 1))
+	)
+	if ticks < 0 {
+		return 0
+	}
+	return ticks
+}
+
+// DifficultyMultiplier returns the difficulty multiplier used by ScoreV1,
+// computed from HP, CS, OD and the object density of the beatmap.
+func (b Beatmap) DifficultyMultiplier() int {
+	drainLength := 0
+	if len(b.HitObjects) > 0 {
+		breakLength := 0
+		for _, bt := range b.BreakTimes {
+			breakLength += bt.EndTime - bt.StartTime
+		}
+		drainLength = (b.HitObjects[len(b.HitObjects)-1].StartTime - b.HitObjects[0].StartTime - breakLength) / 1000
+	}
+	objectToDrainRatio := 16.0
+	if drainLength != 0 {
+		objectToDrainRatio = math.Max(0, math.Min(16, float64(len(b.HitObjects))/float64(drainLength)*8))
+	}
+	return int(math.Floor((b.HPDrainRate+b.OverallDifficulty+b.CircleSize+objectToDrainRatio)/38*5 + 0.5))
+}
+
+// Walks through the hit objects with a perfect play,
+// calling hit for every judgement given.
+// hitValue is the base score of the judgement, comboBonus is whether the
+// combo bonus applies, and increaseCombo is whether the combo is increased.
+func (b Beatmap) simulatePerfectPlay(hit func(hitValue int, comboBonus, increaseCombo bool)) {
+	// Spinner rotation speeds, in rotations per second
+	var (
+		maxRotations = 477.0 / 60
+		minRotations = b.SpinsPerSecond()
+	)
+	for _, h := range b.HitObjects {
+		switch h.ObjectName {
+		case ObjectCircle:
+			hit(300, true, true)
+		case ObjectSlider:
+			ticks := b.sliderTicksPerSpan(h)
+			hit(30, false, true) // Head
+			for span := 0; span < h.RepeatCount; span++ {
+				for i := 0; i < ticks; i++ {
+					hit(10, false, true)
+				}
+				hit(30, false, true) // Repeat or tail
+			}
+			hit(300, true, false)
+		case ObjectSpinner:
+			var (
+				seconds         = float64(h.EndTime-h.StartTime) / 1000
+				totalHalfSpins  = int(seconds * maxRotations * 2)
+				halfSpinsNeeded = int(seconds*minRotations) + 3
+			)
+			for i := 0; i <= totalHalfSpins; i++ {
+				if i > halfSpinsNeeded && (i-halfSpinsNeeded)%2 == 0 {
+					hit(1100, false, false) // Bonus spin
+				} else if i > 1 && i%2 == 0 {
+					hit(100, false, false)
+				}
+			}
+			hit(300, true, true)
+		}
+	}
+}
+
+// MaxScore returns the maximum ScoreV1 achievable on an osu!standard beatmap
+// with the given mods.
+func (b Beatmap) MaxScore(mods Mods) int {
+	var (
+		multiplier = float64(b.DifficultyMultiplier()) * mods.ScoreMultiplier()
+		score      = 0
+		combo      = 0
+	)
+	b.simulatePerfectPlay(func(hitValue int, comboBonus, increaseCombo bool) {
+		score += hitValue
+		if comboBonus && combo > 1 {
+			score += int(float64(combo-1) * (float64(hitValue/25) * multiplier))
+		}
+		if increaseCombo {
+			combo++
+		}
+	})
+	return score
+}
+
+// ScoreV2 returns the ScoreV2 of a play on an osu!standard beatmap,
+// given its judgements and mods.
+// Spinner bonus is not counted. As the judgements only hold the max combo,
+// the play is assumed to have a single combo streak: the combo portion of
+// the other streaks is not counted, so plays with combo breaks score slightly lower
+// than in the game.
+func (b Beatmap) ScoreV2(mods Mods, j Judgements) int {
+	maxCombo := 0
+	b.simulatePerfectPlay(func(_ int, _, increaseCombo bool) {
+		if increaseCombo {
+			maxCombo++
+		}
+	})
+	// The combo portion grows with the square root of the combo.
+	comboPortion := func(combo int) float64 {
+		sum := 0.0
+		for c := 1; c <= combo; c++ {
+			sum += math.Sqrt(float64(c))
+		}
+		return sum
+	}
+	comboRatio := 1.0
+	if maxCombo > 0 {
+		comboRatio = math.Min(1, comboPortion(j.MaxCombo)/comboPortion(maxCombo))
+	}
+	var (
+		objects  = j.Count300 + j.Count100 + j.Count50 + j.CountMiss
+		accRatio = math.Pow(j.Accuracy(), 10)
+	)
+	if n := len(b.HitObjects); n > 0 && objects < n {
+		// Only the objects judged so far count
+		accRatio *= float64(objects) / float64(n)
+	}
+	return int(math.Floor((700000*comboRatio+300000*accRatio)*mods.ScoreV2Multiplier() + 0.5))
+}
@@ -0,0 +1,75 @@
+package parser
+
+import "math"
+
+// SliderEventType is the kind of a slider event.
+type SliderEventType int
+
+// The kinds of slider events.
+const (
+	SliderHead SliderEventType = iota
+	SliderTick
+	SliderRepeat
+	SliderTail
+)
+
+// SliderEvent is a point of a slider that is judged: its head, ticks, repeats and tail.
+type SliderEvent struct {
+	Type     SliderEventType
+	Time     float64 // in ms
+	Progress float64 // Progress along the slider path, from 0 (head) to 1 (end)
+	Span     int     // Index of the span the event belongs to
+}
+
+// The game checks the slider tail slightly before the slider's end.
+const sliderTailLenience = 36
+
+// LegacyTailTime returns the time at which the game checks the tail of a slider.
+func (h HitObject) LegacyTailTime() float64 {
+	return math.Max(float64(h.StartTime)+float64(h.Duration)/2, float64(h.EndTime)-sliderTailLenience)
+}
+
+// SliderEvents returns the events of a slider, sorted by time.
+// Objects that are not sliders have no events.
+func (b Beatmap) SliderEvents(h HitObject) []SliderEvent {
+	if h.ObjectName != ObjectSlider || h.RepeatCount <= 0 {
+		return nil
+	}
+	var (
+		events       = []SliderEvent{{Type: SliderHead, Time: float64(h.StartTime)}}
+		ticks        = b.sliderTicksPerSpan(h)
+		spanDuration = float64(h.Duration) / float64(h.RepeatCount)
+		tickDistance = 0.0
+	)
+	if tp := b.getTimingPoint(float64(h.StartTime)); tp != nil && b.SliderTickRate > 0 {
+		tickDistance = b.SliderMultiplier * 100 * tp.Velocity / float64(b.SliderTickRate)
+	}
+	for span := 0; span < h.RepeatCount; span++ {
+		spanStart := float64(h.StartTime) + float64(span)*spanDuration
+		reversed := span%2 == 1
+		// Ticks stay at the same positions on every span, so reversed spans
+		// meet them from the end of the path.
+		for i := 1; i <= ticks && h.PixelLength > 0; i++ {
+			n := i
+			if reversed {
+				n = ticks + 1 - i
+			}
+			progress := float64(n) * tickDistance / h.PixelLength
+			timeProgress := progress
+			if reversed {
+				timeProgress = 1 - progress
+			}
+			events = append(events, SliderEvent{SliderTick, spanStart + timeProgress*spanDuration, progress, span})
+		}
+		end := SliderEvent{SliderRepeat, spanStart + spanDuration, 1, span}
+		if reversed {
+			end.Progress = 0
+		}
+		if span == h.RepeatCount-1 {
+			end.Type = SliderTail
+			end.Time = float64(h.EndTime)
+		}
+		events = append(events, end)
+	}
+	return events
+}
@@ -0,0 +1,228 @@
+package parser
+
+import "math"
+
+// SliderPath is the path followed by a slider ball,
+// approximated by a polyline and cut to the slider's pixel length.
+type SliderPath struct {
+	Points  []Point
+	lengths []float64 // Cumulative length at each point
+}
+
+// Length returns the length of the path, in osu!pixels.
+func (p SliderPath) Length() float64 {
+	if len(p.lengths) == 0 {
+		return 0
+	}
+	return p.lengths[len(p.lengths)-1]
+}
+
+// PositionAt returns the position at the given progress along the path,
+// from 0 (head) to 1 (end).
+func (p SliderPath) PositionAt(progress float64) Point {
+	if len(p.Points) == 0 {
+		return Point{}
+	}
+	dist := math.Max(0, math.Min(1, progress)) * p.Length()
+	// Binary search the segment containing the distance
+	lo, hi := 0, len(p.lengths)-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if p.lengths[mid] < dist {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return p.Points[0]
+	}
+	segment := p.lengths[lo] - p.lengths[lo-1]
+	if segment == 0 {
+		return p.Points[lo]
+	}
+	t := (dist - p.lengths[lo-1]) / segment
+	return lerpPoint(p.Points[lo-1], p.Points[lo], t)
+}
+
+func lerpPoint(a, b Point, t float64) Point {
+	return Point{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}
+}
+
+// Path returns the path of a slider.
+// Other objects have a path made of their position only.
+func (h HitObject) Path() SliderPath {
+	if h.ObjectName != ObjectSlider || len(h.Points) < 2 {
+		return newSliderPath([]Point{h.Position}, 0)
+	}
+	var pts []Point
+	switch h.CurveType {
+	case CurveBezier:
+		pts = bezierPath(h.Points)
+	case CurveCatmull:
+		pts = catmullPath(h.Points)
+	case CurvePassThrough:
+		if pts = circularArcPath(h.Points); pts == nil {
+			pts = bezierPath(h.Points)
+		}
+	default:
+		pts = linearPath(h.Points)
+	}
+	return newSliderPath(pts, h.PixelLength)
+}
+
+// ProgressAt returns the progress of the slider ball along the path at the given time,
+// from 0 (head) to 1 (end), taking repeats into account.
+func (h HitObject) ProgressAt(time float64) float64 {
+	if h.ObjectName != ObjectSlider || h.Duration <= 0 || h.RepeatCount <= 0 {
+		return 0
+	}
+	spanDuration := float64(h.Duration) / float64(h.RepeatCount)
+	p := math.Max(0, math.Min(float64(h.RepeatCount), (time-float64(h.StartTime))/spanDuration))
+	span := math.Floor(p)
+	if span == float64(h.RepeatCount) {
+		span--
+	}
+	p -= span
+	if int(span)%2 == 1 {
+		p = 1 - p
+	}
+	return p
+}
+
+// Build a path from a polyline, cutting or extending it to the expected length.
+// A non-positive length keeps the polyline as is.
+func newSliderPath(pts []Point, length float64) SliderPath {
+	p := SliderPath{Points: []Point{pts[0]}, lengths: []float64{0}}
+	for i := 1; i < len(pts); i++ {
+		d := p.lengths[len(p.lengths)-1]
+		if length > 0 && d >= length {
+			break
+		}
+		seg := distancePoints(pts[i-1], pts[i])
+		if length > 0 && d+seg > length {
+			p.Points = append(p.Points, lerpPoint(pts[i-1], pts[i], (length-d)/seg))
+			p.lengths = append(p.lengths, length)
+			break
+		}
+		p.Points = append(p.Points, pts[i])
+		p.lengths = append(p.lengths, d+seg)
+	}
+	// The path is extended in a straight line if it is too short
+	if d := p.Length(); length > 0 && d < length && len(pts) > 1 {
+		a, b := pts[len(pts)-2], pts[len(pts)-1]
+		if seg := distancePoints(a, b); seg > 0 {
+			p.Points = append(p.Points, lerpPoint(b, Point{2*b.X - a.X, 2*b.Y - a.Y}, (length-d)/seg))
+			p.lengths = append(p.lengths, length)
+		}
+	}
+	return p
+}
+
+func linearPath(points []Point) []Point {
+	pts := make([]Point, len(points))
+	copy(pts, points)
+	return pts
+}
+
+// Bezier curves are split into segments on repeated (red) control points.
+func bezierPath(points []Point) []Point {
+	var (
+		pts   = []Point{points[0]}
+		start = 0
+	)
+	for i := 1; i <= len(points); i++ {
+		if i == len(points) || points[i] == points[i-1] {
+			pts = append(pts, bezierSegment(points[start:i])[1:]...)
+			start = i
+		}
+	}
+	return pts
+}
+
+// Approximate a single bezier curve by evaluating it at regular steps.
+func bezierSegment(points []Point) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	controlLength := 0.0
+	for i := 1; i < len(points); i++ {
+		controlLength += distancePoints(points[i-1], points[i])
+	}
+	steps := int(math.Min(1000, math.Max(10, controlLength/2)))
+	pts := make([]Point, 0, steps+1)
+	work := make([]Point, len(points))
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(steps)
+		// De Casteljau's algorithm
+		copy(work, points)
+		for n := len(work) - 1; n > 0; n-- {
+			for i := 0; i < n; i++ {
+				work[i] = lerpPoint(work[i], work[i+1], t)
+			}
+		}
+		pts = append(pts, work[0])
+	}
+	return pts
+}
+
+func catmullPath(points []Point) []Point {
+	const steps = 50
+	pts := []Point{points[0]}
+	for i := 0; i+1 < len(points); i++ {
+		var (
+			v2 = points[i]
+			v1 = v2
+			v3 = points[i+1]
+		)
+		if i > 0 {
+			v1 = points[i-1]
+		}
+		v4 := Point{2*v3.X - v2.X, 2*v3.Y - v2.Y}
+		if i+2 < len(points) {
+			v4 = points[i+2]
+		}
+		at := func(a, b, c, d, t float64) float64 {
+			return 0.5 * (2*b + (-a+c)*t + (2*a-5*b+4*c-d)*t*t + (-a+3*b-3*c+d)*t*t*t)
+		}
+		for s := 1; s <= steps; s++ {
+			t := float64(s) / steps
+			pts = append(pts, Point{at(v1.X, v2.X, v3.X, v4.X, t), at(v1.Y, v2.Y, v3.Y, v4.Y, t)})
+		}
+	}
+	return pts
+}
+
+// Approximate the arc of circle going through 3 points.
+// Returns nil if the points do not form a proper circle.
+func circularArcPath(points []Point) []Point {
+	if len(points) != 3 {
+		return nil
+	}
+	a, b, c := points[0], points[1], points[2]
+	if math.Abs((b.Y-a.Y)*(c.X-a.X)-(b.X-a.X)*(c.Y-a.Y)) < 1e-3 {
+		return nil // Aligned points
+	}
+	circ := getCircumCircle(a, b, c)
+	var (
+		thetaStart = math.Atan2(a.Y-circ.C.Y, a.X-circ.C.X)
+		thetaEnd   = math.Atan2(c.Y-circ.C.Y, c.X-circ.C.X)
+		dir        = 1.0
+	)
+	for thetaEnd < thetaStart {
+		thetaEnd += 2 * math.Pi
+	}
+	thetaRange := thetaEnd - thetaStart
+	// Go the other way around if B is on the other side of AC
+	if (c.Y-a.Y)*(b.X-a.X)-(c.X-a.X)*(b.Y-a.Y) < 0 {
+		dir = -1
+		thetaRange = 2*math.Pi - thetaRange
+	}
+	steps := int(math.Min(1000, math.Max(2, math.Ceil(thetaRange*circ.R/2))))
+	pts := make([]Point, 0, steps+1)
+	for s := 0; s <= steps; s++ {
+		theta := thetaStart + dir*thetaRange*float64(s)/float64(steps)
+		pts = append(pts, Point{circ.C.X + circ.R*math.Cos(theta), circ.C.Y + circ.R*math.Sin(theta)})
+	}
+	return pts
+}
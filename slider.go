@@ -2,7 +2,7 @@ package parser
 
 import "math"
 
-func getSliderEndPoint(sliderType string, sliderLength float64, points []Point) Point {
+func getSliderEndPoint(sliderType CurveType, sliderLength float64, points []Point) Point {
 	if len(points) < 2 {
 		return Point{} // Wtf slider with less than 2 points?
 	}
@@ -12,11 +12,11 @@ func getSliderEndPoint(sliderType string, sliderLength float64, points []Point)
 	switch sliderType {
 	default:
 		return Point{}
-	case "linear":
+	case CurveLinear:
 		return pointOnLine(points[0], points[1], sliderLength)
-	case "catmull":
+	case CurveCatmull:
 		return Point{} // unsupported
-	case "bezier":
+	case CurveBezier:
 		pts := make([]Point, len(points))
 		copy(pts, points)
 		var (
@@ -44,9 +44,9 @@ func getSliderEndPoint(sliderType string, sliderLength float64, points []Point)
 			return Point{}
 		}
 		return *px
-	case "pass-through":
+	case CurvePassThrough:
 		if len(points) > 3 {
-			return getSliderEndPoint("bezier", sliderLength, points)
+			return getSliderEndPoint(CurveBezier, sliderLength, points)
 		}
 		var (
 			p1 = points[0]
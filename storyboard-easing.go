@@ -0,0 +1,165 @@
+package parser
+
+import "math"
+
+// The easing functions of storyboard commands.
+const (
+	EasingLinear Easing = iota
+	EasingOut
+	EasingIn
+	EasingQuadIn
+	EasingQuadOut
+	EasingQuadInOut
+	EasingCubicIn
+	EasingCubicOut
+	EasingCubicInOut
+	EasingQuartIn
+	EasingQuartOut
+	EasingQuartInOut
+	EasingQuintIn
+	EasingQuintOut
+	EasingQuintInOut
+	EasingSineIn
+	EasingSineOut
+	EasingSineInOut
+	EasingExpoIn
+	EasingExpoOut
+	EasingExpoInOut
+	EasingCircIn
+	EasingCircOut
+	EasingCircInOut
+	EasingElasticIn
+	EasingElasticOut
+	EasingElasticHalfOut
+	EasingElasticQuarterOut
+	EasingElasticInOut
+	EasingBackIn
+	EasingBackOut
+	EasingBackInOut
+	EasingBounceIn
+	EasingBounceOut
+	EasingBounceInOut
+)
+
+// Overshoot of the back easings.
+const backOvershoot = 1.70158
+
+// Turns an "in" easing into the matching "out" easing, and the other way around.
+func reverseEasing(f func(float64) float64) func(float64) float64 {
+	return func(t float64) float64 { return 1 - f(1-t) }
+}
+
+// Turns an "in" easing into the matching "in-out" easing.
+func inOutEasing(f func(float64) float64) func(float64) float64 {
+	return func(t float64) float64 {
+		if t < 0.5 {
+			return f(2*t) / 2
+		}
+		return 1 - f(2-2*t)/2
+	}
+}
+
+func powerEasing(n float64) func(float64) float64 {
+	return func(t float64) float64 { return math.Pow(t, n) }
+}
+
+func elasticOut(period float64) func(float64) float64 {
+	return func(t float64) float64 {
+		return math.Pow(2, -10*t)*math.Sin((period*t-0.075)*2*math.Pi/0.3) + 1
+	}
+}
+
+// The in-out elastic easing has its own period of 0.45, instead of 0.3.
+func elasticInOut(t float64) float64 {
+	const period = 0.45
+	t = 2*t - 1
+	wave := math.Sin((t - period/4) * 2 * math.Pi / period)
+	if t < 0 {
+		return -0.5 * math.Pow(2, 10*t) * wave
+	}
+	return 0.5*math.Pow(2, -10*t)*wave + 1
+}
+
+func backIn(s float64) func(float64) float64 {
+	return func(t float64) float64 { return t * t * ((s+1)*t - s) }
+}
+
+func bounceOut(t float64) float64 {
+	switch {
+	case t < 1/2.75:
+		return 7.5625 * t * t
+	case t < 2/2.75:
+		t -= 1.5 / 2.75
+		return 7.5625*t*t + 0.75
+	case t < 2.5/2.75:
+		t -= 2.25 / 2.75
+		return 7.5625*t*t + 0.9375
+	}
+	t -= 2.625 / 2.75
+	return 7.5625*t*t + 0.984375
+}
+
+var (
+	sineIn = func(t float64) float64 { return 1 - math.Cos(t*math.Pi/2) }
+	expoIn = func(t float64) float64 {
+		if t == 0 {
+			return 0
+		}
+		return math.Pow(2, 10*(t-1))
+	}
+	circIn    = func(t float64) float64 { return 1 - math.Sqrt(1-t*t) }
+	elasticIn = reverseEasing(elasticOut(1))
+	bounceIn  = reverseEasing(bounceOut)
+)
+
+var easingFuncs = map[Easing]func(float64) float64{
+	EasingOut:               reverseEasing(powerEasing(2)),
+	EasingIn:                powerEasing(2),
+	EasingQuadIn:            powerEasing(2),
+	EasingQuadOut:           reverseEasing(powerEasing(2)),
+	EasingQuadInOut:         inOutEasing(powerEasing(2)),
+	EasingCubicIn:           powerEasing(3),
+	EasingCubicOut:          reverseEasing(powerEasing(3)),
+	EasingCubicInOut:        inOutEasing(powerEasing(3)),
+	EasingQuartIn:           powerEasing(4),
+	EasingQuartOut:          reverseEasing(powerEasing(4)),
+	EasingQuartInOut:        inOutEasing(powerEasing(4)),
+	EasingQuintIn:           powerEasing(5),
+	EasingQuintOut:          reverseEasing(powerEasing(5)),
+	EasingQuintInOut:        inOutEasing(powerEasing(5)),
+	EasingSineIn:            sineIn,
+	EasingSineOut:           reverseEasing(sineIn),
+	EasingSineInOut:         inOutEasing(sineIn),
+	EasingExpoIn:            expoIn,
+	EasingExpoOut:           reverseEasing(expoIn),
+	EasingExpoInOut:         inOutEasing(expoIn),
+	EasingCircIn:            circIn,
+	EasingCircOut:           reverseEasing(circIn),
+	EasingCircInOut:         inOutEasing(circIn),
+	EasingElasticIn:         elasticIn,
+	EasingElasticOut:        elasticOut(1),
+	EasingElasticHalfOut:    elasticOut(0.5),
+	EasingElasticQuarterOut: elasticOut(0.25),
+	EasingElasticInOut:      elasticInOut,
+	EasingBackIn:            backIn(backOvershoot),
+	EasingBackOut:           reverseEasing(backIn(backOvershoot)),
+	EasingBackInOut:         inOutEasing(backIn(backOvershoot * 1.525)),
+	EasingBounceIn:          bounceIn,
+	EasingBounceOut:         bounceOut,
+	EasingBounceInOut:       inOutEasing(bounceIn),
+}
+
+// Apply maps the progress of a command, between 0 and 1, to the eased progress.
+// Unknown easings are linear.
+func (e Easing) Apply(t float64) float64 {
+	if t <= 0 {
+		return 0
+	}
+	if t >= 1 {
+		return 1
+	}
+	if f, ok := easingFuncs[e]; ok {
+		return f(t)
+	}
+	return t
+}
@@ -0,0 +1,239 @@
+package parser
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// SpriteState is the state of a storyboard sprite at a given time.
+type SpriteState struct {
+	Visible  bool       // Whether the sprite is drawn
+	Position Point      // In storyboard pixels (640x480)
+	Scale    Point      // Uniform scale multiplied by vector scale
+	Rotation float64    // In radians, clockwise
+	Opacity  float64    // From 0 to 1
+	Colour   color.RGBA // Tint of the sprite, always opaque
+	FlipH    bool
+	FlipV    bool
+	Additive bool // Additive blending
+}
+
+// A command of a single value.
+type scalarCommand struct {
+	StartTime float64
+	EndTime   float64
+	Easing    Easing
+	From      float64
+	To        float64
+}
+
+// The commands changing a single value, sorted by start time.
+type timeline []scalarCommand
+
+// Gets the value at a given time. Before the first command, the value is
+// the first command's start value. Once a command starts it overrides the
+// commands that started before it, and its end value is held after it ends.
+func (tl timeline) valueAt(t, initial float64) float64 {
+	if len(tl) == 0 {
+		return initial
+	}
+	if t < tl[0].StartTime {
+		return tl[0].From
+	}
+	c := tl[sort.Search(len(tl), func(i int) bool { return tl[i].StartTime > t })-1]
+	if t >= c.EndTime {
+		return c.To
+	}
+	p := c.Easing.Apply((t - c.StartTime) / (c.EndTime - c.StartTime))
+	return c.From + (c.To-c.From)*p
+}
+
+// The timelines of every value of a sprite.
+type spriteTimelines struct {
+	X, Y, Scale, ScaleX, ScaleY, Rotation, Opacity, R, G, B timeline
+	FlipH, FlipV, Additive                                  []Command
+	StartTime, EndTime                                      float64
+	HasCommands                                             bool
+}
+
+// Expanded returns the commands of the sprite with its loops unrolled, sorted by start time.
+// Trigger commands depend on gameplay and are not included.
+func (s Sprite) Expanded() []Command {
+	cmds := make([]Command, 0, len(s.Commands))
+	cmds = append(cmds, s.Commands...)
+	for _, l := range s.Loops {
+		cmds = append(cmds, l.Expanded()...)
+	}
+	sort.SliceStable(cmds, func(i, j int) bool { return cmds[i].StartTime < cmds[j].StartTime })
+	return cmds
+}
+
+// Expanded returns the commands of the loop for every iteration, at absolute times.
+// Each iteration lasts from the earliest command start to the latest command end,
+// and the loop runs at least once.
+func (l Loop) Expanded() []Command {
+	cmds := make([]Command, 0)
+	if len(l.Commands) == 0 {
+		return cmds
+	}
+	start, end := l.Commands[0].StartTime, l.Commands[0].EndTime
+	for _, c := range l.Commands {
+		if c.StartTime < start {
+			start = c.StartTime
+		}
+		if c.EndTime > end {
+			end = c.EndTime
+		}
+	}
+	count := l.LoopCount
+	if count < 1 {
+		count = 1
+	}
+	for i := 0; i < count; i++ {
+		offset := l.StartTime + i*(end-start)
+		for _, c := range l.Commands {
+			c.StartTime += offset
+			c.EndTime += offset
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+func (s Sprite) timelines() (tl spriteTimelines) {
+	add := func(t *timeline, c Command, i int) {
+		*t = append(*t, scalarCommand{float64(c.StartTime), float64(c.EndTime), c.Easing, c.StartValues[i], c.EndValues[i]})
+	}
+	for j, c := range s.Expanded() {
+		if j == 0 || float64(c.StartTime) < tl.StartTime {
+			tl.StartTime = float64(c.StartTime)
+		}
+		if j == 0 || float64(c.EndTime) > tl.EndTime {
+			tl.EndTime = float64(c.EndTime)
+		}
+		tl.HasCommands = true
+		if c.Type != CommandParameter && len(c.StartValues) < commandValueCount[c.Type] {
+			continue
+		}
+		switch c.Type {
+		case CommandFade:
+			add(&tl.Opacity, c, 0)
+		case CommandMove:
+			add(&tl.X, c, 0)
+			add(&tl.Y, c, 1)
+		case CommandMoveX:
+			add(&tl.X, c, 0)
+		case CommandMoveY:
+			add(&tl.Y, c, 0)
+		case CommandScale:
+			add(&tl.Scale, c, 0)
+		case CommandVectorScale:
+			add(&tl.ScaleX, c, 0)
+			add(&tl.ScaleY, c, 1)
+		case CommandRotate:
+			add(&tl.Rotation, c, 0)
+		case CommandColour:
+			add(&tl.R, c, 0)
+			add(&tl.G, c, 1)
+			add(&tl.B, c, 2)
+		case CommandParameter:
+			switch c.Parameter {
+			case "H":
+				tl.FlipH = append(tl.FlipH, c)
+			case "V":
+				tl.FlipV = append(tl.FlipV, c)
+			case "A":
+				tl.Additive = append(tl.Additive, c)
+			}
+		}
+	}
+	return
+}
+
+// Parameters are active while their command runs,
+// or forever if the command starts and ends at the same time.
+func parameterAt(cmds []Command, t float64) bool {
+	for _, c := range cmds {
+		if float64(c.StartTime) <= t && (float64(c.EndTime) > t || c.StartTime == c.EndTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func colourComponent(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Floor(v+0.5))))
+}
+
+func (tl spriteTimelines) stateAt(s Sprite, t float64) SpriteState {
+	st := SpriteState{
+		Position: Point{X: tl.X.valueAt(t, s.X), Y: tl.Y.valueAt(t, s.Y)},
+		Scale: Point{
+			X: tl.Scale.valueAt(t, 1) * tl.ScaleX.valueAt(t, 1),
+			Y: tl.Scale.valueAt(t, 1) * tl.ScaleY.valueAt(t, 1),
+		},
+		Rotation: tl.Rotation.valueAt(t, 0),
+		Opacity:  math.Max(0, math.Min(1, tl.Opacity.valueAt(t, 1))),
+		Colour: color.RGBA{
+			R: colourComponent(tl.R.valueAt(t, 255)),
+			G: colourComponent(tl.G.valueAt(t, 255)),
+			B: colourComponent(tl.B.valueAt(t, 255)),
+			A: 255,
+		},
+		FlipH:    parameterAt(tl.FlipH, t),
+		FlipV:    parameterAt(tl.FlipV, t),
+		Additive: parameterAt(tl.Additive, t),
+	}
+	// Sprites only exist between their first and last commands
+	st.Visible = tl.HasCommands && t >= tl.StartTime && t <= tl.EndTime &&
+		st.Opacity > 0 && st.Scale.X != 0 && st.Scale.Y != 0
+	return st
+}
+
+// StateAt returns the state of the sprite at a given time, in ms.
+func (s Sprite) StateAt(t float64) SpriteState {
+	return s.timelines().stateAt(s, t)
+}
+
+// SpriteStateAt returns the state of every sprite of the storyboard at a given time, in ms.
+func (s Storyboard) SpriteStateAt(t float64) []SpriteState {
+	states := make([]SpriteState, 0, len(s.Sprites))
+	for _, sp := range s.Sprites {
+		states = append(states, sp.StateAt(t))
+	}
+	return states
+}
+
+// Lifetime returns the time range in which the sprite exists, from the start of its first
+// command to the end of its last one. Trigger commands are not taken into account.
+// ok is false if the sprite has no commands.
+func (s Sprite) Lifetime() (start, end float64, ok bool) {
+	tl := s.timelines()
+	return tl.StartTime, tl.EndTime, tl.HasCommands
+}
+
+// NeverVisible tells whether the sprite can never be seen: it has no commands,
+// or its opacity or scale is always 0. Sprites with trigger commands may be visible.
+func (s Sprite) NeverVisible() bool {
+	for _, tr := range s.Triggers {
+		if len(tr.Commands) > 0 {
+			return false
+		}
+	}
+	tl := s.timelines()
+	return !tl.HasCommands || alwaysZero(tl.Opacity) || alwaysZero(tl.Scale) ||
+		alwaysZero(tl.ScaleX) || alwaysZero(tl.ScaleY)
+}
+
+func alwaysZero(tl timeline) bool {
+	if len(tl) == 0 {
+		return false
+	}
+	for _, c := range tl {
+		if c.From != 0 || c.To != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// WriteFile writes the storyboard as an .osb file.
+func (s Storyboard) WriteFile(file string) error {
+	return ioutil.WriteFile(file, s.Bytes(), 0644)
+}
+
+// Bytes returns the storyboard encoded as an .osb file.
+func (s Storyboard) Bytes() []byte {
+	var buf bytes.Buffer
+	s.Write(&buf)
+	return buf.Bytes()
+}
+
+// Write encodes the storyboard as an .osb file.
+func (s Storyboard) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	w.WriteString("[Events]\r\n//Background and Video events\r\n")
+	s.writeEvents(w)
+	return w.Flush()
+}
+
+// WriteEvents encodes the storyboard sprites and samples as [Events] lines,
+// without the section header.
+// It does not write the background, video and break lines of a beatmap:
+// use Beatmap.WriteEvents to rebuild the whole section of an .osu file.
+func (s Storyboard) WriteEvents(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	s.writeEvents(w)
+	return w.Flush()
+}
+
+func (s Storyboard) writeEvents(w *bufio.Writer) {
+	// Sprites are drawn by layer, then in the order they are written
+	for layer := LayerBackground; layer <= LayerOverlay; layer++ {
+		fmt.Fprintf(w, "//Storyboard Layer %d (%s)\r\n", layer, layer)
+		for _, sp := range s.Sprites {
+			if sp.Layer == layer {
+				writeSprite(w, sp)
+			}
+		}
+	}
+	w.WriteString("//Storyboard Sound Samples\r\n")
+	for _, sa := range s.Samples {
+		fmt.Fprintf(w, "Sample,%d,%d,\"%s\",%d\r\n", sa.Time, sa.Layer, sa.Filepath, sa.Volume)
+	}
+}
+
+// Formats a number as short as possible.
+func formatStoryboardNumber(v float64) string {
+	if v == 0 {
+		v = 0 // No "-0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeSprite(w *bufio.Writer, sp Sprite) {
+	x, y := formatStoryboardNumber(sp.X), formatStoryboardNumber(sp.Y)
+	if sp.IsAnimation {
+		fmt.Fprintf(w, "Animation,%s,%s,\"%s\",%s,%s,%d,%s,%s\r\n", sp.Layer, sp.Origin, sp.Filepath, x, y,
+			sp.FrameCount, formatStoryboardNumber(sp.FrameDelay), sp.LoopType)
+	} else {
+		fmt.Fprintf(w, "Sprite,%s,%s,\"%s\",%s,%s\r\n", sp.Layer, sp.Origin, sp.Filepath, x, y)
+	}
+	writeCommands(w, " ", sp.Commands)
+	for _, l := range sp.Loops {
+		fmt.Fprintf(w, " L,%d,%d\r\n", l.StartTime, l.LoopCount)
+		writeCommands(w, "  ", l.Commands)
+	}
+	for _, t := range sp.Triggers {
+		fmt.Fprintf(w, " T,%s,%d,%d", t.Name, t.StartTime, t.EndTime)
+		if t.GroupNumber != 0 {
+			fmt.Fprintf(w, ",%d", t.GroupNumber)
+		}
+		w.WriteString("\r\n")
+		writeCommands(w, "  ", t.Commands)
+	}
+}
+
+func sameValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Write commands in the shortest form: the end time is omitted when it equals the
+// start time, and the end values when they equal the start values. Consecutive
+// commands of the same type, easing and duration continuing each other are chained.
+func writeCommands(w *bufio.Writer, indent string, cmds []Command) {
+	for i := 0; i < len(cmds); {
+		c := cmds[i]
+		fmt.Fprintf(w, "%s%s,%d,%d,", indent, c.Type, c.Easing, c.StartTime)
+		if c.EndTime != c.StartTime {
+			w.WriteString(strconv.Itoa(c.EndTime))
+		}
+		if c.Type == CommandParameter {
+			fmt.Fprintf(w, ",%s\r\n", c.Parameter)
+			i++
+			continue
+		}
+		values := [][]float64{c.StartValues, c.EndValues}
+		last := c
+		for i++; i < len(cmds) && c.EndTime > c.StartTime; i++ {
+			next := cmds[i]
+			if next.Type != c.Type || next.Easing != c.Easing || next.StartTime != last.EndTime ||
+				next.EndTime-next.StartTime != c.EndTime-c.StartTime || !sameValues(next.StartValues, last.EndValues) {
+				break
+			}
+			values = append(values, next.EndValues)
+			last = next
+		}
+		if len(values) == 2 && sameValues(c.StartValues, c.EndValues) {
+			values = values[:1]
+		}
+		for _, vs := range values {
+			for _, v := range vs {
+				w.WriteString(",")
+				w.WriteString(formatStoryboardNumber(v))
+			}
+		}
+		w.WriteString("\r\n")
+	}
+}
+
+// Optimize returns a smaller storyboard that displays the same scene:
+//   - sprites that are never visible are removed,
+//   - consecutive or overlapping commands holding the same value are merged,
+//   - values and positions are rounded to the given number of decimals, for shorter output.
+//
+// A negative precision keeps values as they are.
+func (s Storyboard) Optimize(precision int) Storyboard {
+	o := Storyboard{Sprites: make([]Sprite, 0, len(s.Sprites)), Samples: s.Samples}
+	for _, sp := range s.Sprites {
+		if sp.NeverVisible() {
+			continue
+		}
+		sp.X, sp.Y = roundTo(sp.X, precision), roundTo(sp.Y, precision)
+		sp.Commands = optimizeCommands(sp.Commands, precision)
+		loops := make([]Loop, len(sp.Loops))
+		for i, l := range sp.Loops {
+			l.Commands = optimizeCommands(l.Commands, precision)
+			loops[i] = l
+		}
+		sp.Loops = loops
+		triggers := make([]Trigger, len(sp.Triggers))
+		for i, t := range sp.Triggers {
+			t.Commands = optimizeCommands(t.Commands, precision)
+			triggers[i] = t
+		}
+		sp.Triggers = triggers
+		o.Sprites = append(o.Sprites, sp)
+	}
+	return o
+}
+
+func roundTo(v float64, precision int) float64 {
+	if precision < 0 {
+		return v
+	}
+	p := math.Pow(10, float64(precision))
+	return math.Floor(v*p+0.5) / p
+}
+
+// Commands of the same family change the same values, and override each other.
+func commandFamily(t CommandType) CommandType {
+	switch t {
+	case CommandMoveX, CommandMoveY:
+		return CommandMove
+	}
+	return t
+}
+
+func optimizeCommands(cmds []Command, precision int) []Command {
+	out := make([]Command, 0, len(cmds))
+	for _, c := range cmds {
+		start, end := make([]float64, len(c.StartValues)), make([]float64, len(c.EndValues))
+		for i, v := range c.StartValues {
+			start[i] = roundTo(v, precision)
+		}
+		for i, v := range c.EndValues {
+			end[i] = roundTo(v, precision)
+		}
+		c.StartValues, c.EndValues = start, end
+		out = append(out, c)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartTime < out[j].StartTime })
+	// The last kept command of each family
+	last := make(map[CommandType]int)
+	merged := make([]Command, 0, len(out))
+	for _, c := range out {
+		static := c.Type != CommandParameter && sameValues(c.StartValues, c.EndValues)
+		if j, ok := last[commandFamily(c.Type)]; ok && static {
+			p := &merged[j]
+			if p.Type == c.Type && sameValues(p.StartValues, p.EndValues) &&
+				sameValues(p.EndValues, c.StartValues) && c.StartTime <= p.EndTime {
+				if c.EndTime > p.EndTime {
+					p.EndTime = c.EndTime
+				}
+				continue
+			}
+		}
+		last[commandFamily(c.Type)] = len(merged)
+		merged = append(merged, c)
+	}
+	return merged
+}
@@ -0,0 +1,587 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Layer is the storyboard layer an element is drawn on.
+type Layer int
+
+// The storyboard layers, from back to front.
+const (
+	LayerBackground Layer = iota
+	LayerFail
+	LayerPass
+	LayerForeground
+	LayerOverlay
+)
+
+var layerNames = []string{"Background", "Fail", "Pass", "Foreground", "Overlay"}
+
+func (l Layer) String() string {
+	if l >= 0 && int(l) < len(layerNames) {
+		return layerNames[l]
+	}
+	return strconv.Itoa(int(l))
+}
+
+// Origin is the point of an image placed at the element's position.
+type Origin int
+
+// The possible origins.
+const (
+	OriginTopLeft Origin = iota
+	OriginCentre
+	OriginCentreLeft
+	OriginTopRight
+	OriginBottomCentre
+	OriginTopCentre
+	OriginCustom // Same as TopLeft
+	OriginCentreRight
+	OriginBottomLeft
+	OriginBottomRight
+)
+
+var originNames = []string{
+	"TopLeft", "Centre", "CentreLeft", "TopRight", "BottomCentre",
+	"TopCentre", "Custom", "CentreRight", "BottomLeft", "BottomRight",
+}
+
+func (o Origin) String() string {
+	if o >= 0 && int(o) < len(originNames) {
+		return originNames[o]
+	}
+	return strconv.Itoa(int(o))
+}
+
+// LoopType is how an animation loops.
+type LoopType int
+
+// The possible loop types.
+const (
+	LoopForever LoopType = iota
+	LoopOnce
+)
+
+var loopTypeNames = []string{"LoopForever", "LoopOnce"}
+
+func (l LoopType) String() string {
+	if l >= 0 && int(l) < len(loopTypeNames) {
+		return loopTypeNames[l]
+	}
+	return strconv.Itoa(int(l))
+}
+
+// CommandType is the kind of a storyboard command.
+type CommandType string
+
+// The storyboard commands.
+const (
+	CommandFade        CommandType = "F"
+	CommandMove        CommandType = "M"
+	CommandMoveX       CommandType = "MX"
+	CommandMoveY       CommandType = "MY"
+	CommandScale       CommandType = "S"
+	CommandVectorScale CommandType = "V"
+	CommandRotate      CommandType = "R"
+	CommandColour      CommandType = "C"
+	CommandParameter   CommandType = "P"
+)
+
+// Number of values of each command type.
+var commandValueCount = map[CommandType]int{
+	CommandFade:        1,
+	CommandMove:        2,
+	CommandMoveX:       1,
+	CommandMoveY:       1,
+	CommandScale:       1,
+	CommandVectorScale: 2,
+	CommandRotate:      1,
+	CommandColour:      3,
+	CommandParameter:   0,
+}
+
+// Easing is the easing function applied to a command.
+type Easing int
+
+// Storyboard holds the storyboard of a beatmap or of an .osb file.
+type Storyboard struct {
+	Sprites []Sprite `json:"sprites"`
+	Samples []Sample `json:"samples"`
+}
+
+// Sprite is a storyboard image, or an animation.
+type Sprite struct {
+	Layer    Layer     `json:"layer"`
+	Origin   Origin    `json:"origin"`
+	Filepath string    `json:"filepath"`
+	X        float64   `json:"x"`
+	Y        float64   `json:"y"`
+	Commands []Command `json:"commands"`
+	Loops    []Loop    `json:"loops"`
+	Triggers []Trigger `json:"triggers"`
+	// Animations only
+	IsAnimation bool     `json:"isAnimation"`
+	FrameCount  int      `json:"frameCount"`
+	FrameDelay  float64  `json:"frameDelay"`
+	LoopType    LoopType `json:"loopType"`
+}
+
+// Sample is a sound played by the storyboard.
+type Sample struct {
+	Time     int    `json:"time"`
+	Layer    Layer  `json:"layer"` // The sample only plays on the Fail or Pass layer in that state
+	Filepath string `json:"filepath"`
+	Volume   int    `json:"volume"` // From 0 to 100
+}
+
+// Command changes a property of a sprite over time.
+// Commands with more than one set of values are split into consecutive commands.
+type Command struct {
+	Type        CommandType `json:"type"`
+	Easing      Easing      `json:"easing"`
+	StartTime   int         `json:"startTime"`
+	EndTime     int         `json:"endTime"`
+	StartValues []float64   `json:"startValues"`
+	EndValues   []float64   `json:"endValues"`
+	Parameter   string      `json:"parameter"` // Parameter commands only: "H", "V" or "A"
+}
+
+// Loop repeats its commands a number of times.
+// Times of the commands are relative to the loop's start time.
+type Loop struct {
+	StartTime int       `json:"startTime"`
+	LoopCount int       `json:"loopCount"`
+	Commands  []Command `json:"commands"`
+}
+
+// Trigger runs its commands when a game event happens, e.g. "HitSoundClap" or "Passing".
+// Times of the commands are relative to the event's time.
+type Trigger struct {
+	Name        string    `json:"name"`
+	StartTime   int       `json:"startTime"`
+	EndTime     int       `json:"endTime"`
+	GroupNumber int       `json:"groupNumber"`
+	Commands    []Command `json:"commands"`
+}
+
+func newStoryboard() Storyboard {
+	return Storyboard{Sprites: make([]Sprite, 0), Samples: make([]Sample, 0)}
+}
+
+type storyboardParser struct {
+	Storyboard
+	Variables []variable // Sorted by decreasing name length
+	// Invalid lines are errors if Strict is set, and are skipped otherwise, like the game does.
+	Strict    bool
+	Warnings  []error // Errors of the skipped lines
+	sprite    *Sprite
+	loop      *Loop
+	trigger   *Trigger
+	skipGroup bool // Whether the current loop or trigger was invalid
+}
+
+// A [Variables] definition, "$name=value".
+type variable struct {
+	Name  string
+	Value string
+}
+
+func newStoryboardParser() *storyboardParser {
+	return &storyboardParser{Storyboard: newStoryboard(), Variables: make([]variable, 0)}
+}
+
+// ParseStoryboardFile parses an .osb file given a filepath.
+func ParseStoryboardFile(file string) (s Storyboard, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ParseStoryboard(f)
+}
+
+// ParseStoryboard parses a standalone storyboard (.osb) file,
+// substituting the variables of its [Variables] section.
+// Like the game, invalid event lines are skipped.
+func ParseStoryboard(r io.Reader) (s Storyboard, err error) {
+	return parseStoryboard(r, false)
+}
+
+// ParseStoryboardStrict parses a standalone storyboard (.osb) file like ParseStoryboard,
+// but fails on the first invalid event line.
+func ParseStoryboardStrict(r io.Reader) (s Storyboard, err error) {
+	return parseStoryboard(r, true)
+}
+
+func parseStoryboard(r io.Reader, strict bool) (s Storyboard, err error) {
+	var (
+		p       = newStoryboardParser()
+		section string
+		scanner = bufio.NewScanner(r)
+	)
+	p.Strict = strict
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r\n")
+		if match := sectionReg.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			section = strings.ToLower(match[1])
+			continue
+		}
+		switch section {
+		case "variables":
+			p.AddVariable(line)
+		case "events":
+			if err = p.ReadLine(line); err != nil {
+				return
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	return p.Build(), nil
+}
+
+// Build returns the parsed storyboard, with samples sorted by time.
+func (p *storyboardParser) Build() Storyboard {
+	sort.SliceStable(p.Samples, func(i, j int) bool { return p.Samples[i].Time < p.Samples[j].Time })
+	return p.Storyboard
+}
+
+// AddVariable reads a [Variables] line.
+func (p *storyboardParser) AddVariable(line string) {
+	line = strings.TrimSpace(line)
+	eq := strings.Index(line, "=")
+	if !strings.HasPrefix(line, "$") || eq < 0 {
+		return
+	}
+	p.Variables = append(p.Variables, variable{line[:eq], line[eq+1:]})
+	// Longer names are substituted first, so that $ab is not read as $a followed by b
+	sort.SliceStable(p.Variables, func(i, j int) bool { return len(p.Variables[i].Name) > len(p.Variables[j].Name) })
+}
+
+// Substitute the variables in a line.
+func (p *storyboardParser) substitute(line string) string {
+	for _, v := range p.Variables {
+		if !strings.Contains(line, "$") {
+			break
+		}
+		line = strings.Replace(line, v.Name, v.Value, -1)
+	}
+	return line
+}
+
+// Merge returns the storyboard with the elements of another one added after its own,
+// e.g. a difficulty's [Events] storyboard merged with the set's .osb file.
+// Later elements are drawn above earlier ones of the same layer.
+func (s Storyboard) Merge(other Storyboard) Storyboard {
+	sprites := make([]Sprite, 0, len(s.Sprites)+len(other.Sprites))
+	sprites = append(sprites, s.Sprites...)
+	sprites = append(sprites, other.Sprites...)
+	samples := make([]Sample, 0, len(s.Samples)+len(other.Samples))
+	samples = append(samples, s.Samples...)
+	samples = append(samples, other.Samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time < samples[j].Time })
+	return Storyboard{Sprites: sprites, Samples: samples}
+}
+
+// Filenames returns the files used by the storyboard, without duplicates:
+// sprite images, every frame of the animations, and samples.
+func (s Storyboard) Filenames() []string {
+	var (
+		names = make([]string, 0)
+		seen  = make(map[string]bool)
+	)
+	add := func(name string) {
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+	}
+	for _, sp := range s.Sprites {
+		if !sp.IsAnimation {
+			add(sp.Filepath)
+			continue
+		}
+		// Frames are numbered before the extension, "anim.png" gives "anim0.png", "anim1.png"...
+		ext := path.Ext(sp.Filepath)
+		for i := 0; i < sp.FrameCount; i++ {
+			add(strings.TrimSuffix(sp.Filepath, ext) + strconv.Itoa(i) + ext)
+		}
+	}
+	for _, sa := range s.Samples {
+		add(sa.Filepath)
+	}
+	return names
+}
+
+// Gets the nesting depth of a line, given by leading spaces or underscores.
+func storyboardDepth(line string) int {
+	depth := 0
+	for depth < len(line) && (line[depth] == ' ' || line[depth] == '_') {
+		depth++
+	}
+	return depth
+}
+
+// Parse a number that may be written as a float.
+func parseStoryboardInt(str string) (int, error) {
+	if i, err := strconv.Atoi(str); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	return int(f), err
+}
+
+// Parse an enum value either by name or by number.
+func parseEnum(str string, names []string) (int, error) {
+	for i, name := range names {
+		if str == name {
+			return i, nil
+		}
+	}
+	return strconv.Atoi(str)
+}
+
+// ReadLine reads an [Events] line, keeping the storyboard elements.
+// Invalid lines are only reported in strict mode: otherwise they are skipped,
+// along with the commands of an invalid element, loop or trigger.
+func (p *storyboardParser) ReadLine(line string) error {
+	err := p.readLine(line)
+	if err == nil || p.Strict {
+		return err
+	}
+	p.Warnings = append(p.Warnings, err)
+	return nil
+}
+
+func (p *storyboardParser) readLine(line string) (err error) {
+	line = p.substitute(strings.TrimRight(line, " \r\n"))
+	if len(line) == 0 || strings.HasPrefix(line, "//") {
+		return
+	}
+	depth := storyboardDepth(line)
+	members := strings.Split(line[depth:], ",")
+	// Trailing commas are ignored
+	for len(members) > 1 && strings.TrimSpace(members[len(members)-1]) == "" {
+		members = members[:len(members)-1]
+	}
+	if depth == 0 {
+		p.sprite, p.loop, p.trigger = nil, nil, nil
+		switch members[0] {
+		case "Sprite", "4":
+			return p.parseSprite(members, false)
+		case "Animation", "6":
+			return p.parseSprite(members, true)
+		case "Sample", "5":
+			return p.parseSample(members)
+		}
+		return
+	}
+	if p.sprite == nil {
+		return
+	}
+	if depth == 1 {
+		p.loop, p.trigger, p.skipGroup = nil, nil, false
+		switch members[0] {
+		case "L", "T":
+			if members[0] == "L" {
+				err = p.parseLoop(members)
+			} else {
+				err = p.parseTrigger(members)
+			}
+			p.skipGroup = err != nil
+			return
+		}
+	} else if p.skipGroup {
+		return
+	}
+	var cmds []Command
+	if cmds, err = parseCommand(members); err != nil || cmds == nil {
+		return
+	}
+	switch {
+	case depth > 1 && p.loop != nil:
+		p.loop.Commands = append(p.loop.Commands, cmds...)
+	case depth > 1 && p.trigger != nil:
+		p.trigger.Commands = append(p.trigger.Commands, cmds...)
+	default:
+		p.sprite.Commands = append(p.sprite.Commands, cmds...)
+	}
+	return
+}
+
+// Parse Sprite,layer,origin,"filepath",x,y
+// and Animation,layer,origin,"filepath",x,y,frameCount,frameDelay[,looptype]
+func (p *storyboardParser) parseSprite(members []string, animation bool) (err error) {
+	if len(members) < 6 || (animation && len(members) < 8) {
+		return ParseError("Invalid storyboard element: " + strings.Join(members, ","))
+	}
+	s := Sprite{
+		Filepath:    unquote(members[3]),
+		Commands:    make([]Command, 0),
+		Loops:       make([]Loop, 0),
+		Triggers:    make([]Trigger, 0),
+		IsAnimation: animation,
+	}
+	var n int
+	if n, err = parseEnum(members[1], layerNames); err != nil {
+		return
+	}
+	s.Layer = Layer(n)
+	if n, err = parseEnum(members[2], originNames); err != nil {
+		return
+	}
+	s.Origin = Origin(n)
+	if s.X, err = strconv.ParseFloat(members[4], 64); err != nil {
+		return
+	}
+	if s.Y, err = strconv.ParseFloat(members[5], 64); err != nil {
+		return
+	}
+	if animation {
+		if s.FrameCount, err = parseStoryboardInt(members[6]); err != nil {
+			return
+		}
+		if s.FrameDelay, err = strconv.ParseFloat(members[7], 64); err != nil {
+			return
+		}
+		if len(members) > 8 {
+			if n, err = parseEnum(members[8], loopTypeNames); err != nil {
+				return
+			}
+			s.LoopType = LoopType(n)
+		}
+	}
+	p.Sprites = append(p.Sprites, s)
+	p.sprite = &p.Sprites[len(p.Sprites)-1]
+	return
+}
+
+// Parse Sample,time,layer,"filepath"[,volume]
+func (p *storyboardParser) parseSample(members []string) (err error) {
+	if len(members) < 4 {
+		return ParseError("Invalid storyboard sample: " + strings.Join(members, ","))
+	}
+	s := Sample{Filepath: unquote(members[3]), Volume: 100}
+	var n int
+	if s.Time, err = parseStoryboardInt(members[1]); err != nil {
+		return
+	}
+	if n, err = parseEnum(members[2], layerNames); err != nil {
+		return
+	}
+	s.Layer = Layer(n)
+	if len(members) > 4 {
+		if s.Volume, err = parseStoryboardInt(members[4]); err != nil {
+			return
+		}
+	}
+	p.Samples = append(p.Samples, s)
+	return
+}
+
+// Parse L,starttime,loopcount
+func (p *storyboardParser) parseLoop(members []string) (err error) {
+	if len(members) < 3 {
+		return ParseError("Invalid storyboard loop: " + strings.Join(members, ","))
+	}
+	l := Loop{Commands: make([]Command, 0)}
+	if l.StartTime, err = parseStoryboardInt(members[1]); err != nil {
+		return
+	}
+	if l.LoopCount, err = parseStoryboardInt(members[2]); err != nil {
+		return
+	}
+	p.sprite.Loops = append(p.sprite.Loops, l)
+	p.loop = &p.sprite.Loops[len(p.sprite.Loops)-1]
+	return
+}
+
+// Parse T,triggertype,starttime,endtime[,groupnumber]
+func (p *storyboardParser) parseTrigger(members []string) (err error) {
+	if len(members) < 4 {
+		return ParseError("Invalid storyboard trigger: " + strings.Join(members, ","))
+	}
+	t := Trigger{Name: members[1], Commands: make([]Command, 0)}
+	if t.StartTime, err = parseStoryboardInt(members[2]); err != nil {
+		return
+	}
+	if t.EndTime, err = parseStoryboardInt(members[3]); err != nil {
+		return
+	}
+	if len(members) > 4 {
+		if t.GroupNumber, err = parseStoryboardInt(members[4]); err != nil {
+			return
+		}
+	}
+	p.sprite.Triggers = append(p.sprite.Triggers, t)
+	p.trigger = &p.sprite.Triggers[len(p.sprite.Triggers)-1]
+	return
+}
+
+// Parse type,easing,starttime,endtime,values...
+// Unknown command types are ignored.
+func parseCommand(members []string) (cmds []Command, err error) {
+	typ := CommandType(members[0])
+	count, ok := commandValueCount[typ]
+	if !ok {
+		return nil, nil
+	}
+	if len(members) < 5 {
+		return nil, ParseError("Invalid storyboard command: " + strings.Join(members, ","))
+	}
+	c := Command{Type: typ}
+	var n int
+	if n, err = parseStoryboardInt(members[1]); err != nil {
+		return
+	}
+	c.Easing = Easing(n)
+	if c.StartTime, err = parseStoryboardInt(members[2]); err != nil {
+		return
+	}
+	c.EndTime = c.StartTime
+	if members[3] != "" {
+		if c.EndTime, err = parseStoryboardInt(members[3]); err != nil {
+			return
+		}
+	}
+	if typ == CommandParameter {
+		c.Parameter = members[4]
+		c.StartValues, c.EndValues = []float64{}, []float64{}
+		return []Command{c}, nil
+	}
+	values := make([]float64, 0, len(members)-4)
+	for _, v := range members[4:] {
+		var f float64
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			return
+		}
+		values = append(values, f)
+	}
+	if len(values) < count || len(values)%count != 0 {
+		return nil, ParseError("Invalid storyboard command values: " + strings.Join(members, ","))
+	}
+	// A single set of values keeps the value constant
+	if len(values) == count {
+		values = append(values, values...)
+	}
+	// Each extra set of values chains a command of the same duration
+	duration := c.EndTime - c.StartTime
+	for i := 0; i+count < len(values); i += count {
+		seg := c
+		seg.StartTime = c.StartTime + duration*(i/count)
+		seg.EndTime = seg.StartTime + duration
+		seg.StartValues = values[i : i+count]
+		seg.EndValues = values[i+count : i+2*count]
+		cmds = append(cmds, seg)
+	}
+	return
+}